@@ -1,30 +1,71 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Render のイメージにタイムゾーンデータが無い場合に備える
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/raie03/schedule-app/backend/internal/access"
 	"github.com/raie03/schedule-app/backend/internal/db"
+	"github.com/raie03/schedule-app/backend/internal/eventid"
 	"github.com/raie03/schedule-app/backend/internal/handlers"
+	"github.com/raie03/schedule-app/backend/internal/jobs"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/mail"
+	"github.com/raie03/schedule-app/backend/internal/middleware"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+	"github.com/raie03/schedule-app/backend/internal/webhooks"
 )
 
 func main() {
-	// .env ファイルのロード
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// .env ファイルのロード（LOG_LEVEL なども .env から読めるようロガーより先に行う）
+	envErr := godotenv.Load()
+
+	// 構造化ログ（LOG_LEVEL: debug / info / warn / error、LOG_FORMAT: json / text）
+	level, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		slog.Error("Invalid LOG_LEVEL", "error", err)
+		os.Exit(1)
+	}
+	logger := logging.New(os.Stdout, level, os.Getenv("LOG_FORMAT"))
+	slog.SetDefault(logger)
+	if envErr != nil {
+		slog.Info("No .env file found")
 	}
 
 	// データベース接続
 	database, err := db.Connect()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
 	// ルーターの設定
-	router := gin.Default()
+	// gin.Default() のロガーは使わず、リクエストIDを付けた構造化ログを1リクエスト1行で出力する
+	router := gin.New()
+	router.Use(middleware.RequestID(logger), middleware.AccessLog(), middleware.Recovery())
+
+	// クライアントの IP アドレス（IP アドレスごとのレート制限に使う）
+	// 既定ではどのプロキシも信頼せず、X-Forwarded-For を偽装しても接続元のアドレスで数える
+	// TRUSTED_PROXIES: X-Forwarded-For を信頼するプロキシの IP アドレス・CIDR（カンマ区切り）
+	// TRUSTED_PLATFORM: cloudflare / google、またはプラットフォームが接続元を入れるヘッダー名
+	if err := router.SetTrustedProxies(envList("TRUSTED_PROXIES")); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
+	switch platform := os.Getenv("TRUSTED_PLATFORM"); platform {
+	case "":
+	case "cloudflare":
+		router.TrustedPlatform = gin.PlatformCloudflare
+	case "google":
+		router.TrustedPlatform = gin.PlatformGoogleAppEngine
+	default:
+		router.TrustedPlatform = platform
+	}
 
 	// CORSの設定
 	router.Use(cors.New(cors.Config{
@@ -34,23 +75,212 @@ func main() {
 		AllowCredentials: true,
 	}))
 	// router.Use(cors.Default())
-	router.Use(gin.Logger())
+
+	// 最適化ジョブのワーカープール
+	workers, err := strconv.Atoi(os.Getenv("OPTIMIZER_WORKERS"))
+	if err != nil || workers < 1 {
+		workers = 2 // デフォルトのワーカー数
+	}
+	queueSize, err := strconv.Atoi(os.Getenv("OPTIMIZER_QUEUE_SIZE"))
+	if err != nil || queueSize < 1 {
+		queueSize = 32 // デフォルトのキューサイズ
+	}
+	jobManager := jobs.NewManager(database, workers, queueSize)
+
+	// 同期的な最適化エンドポイントのタイムアウト
+	optimizeTimeout, err := time.ParseDuration(os.Getenv("OPTIMIZER_TIMEOUT"))
+	if err != nil {
+		optimizeTimeout = 25 * time.Second // デフォルト（Render のプロキシより短く）
+	}
+
+	// イベントIDの長さと文字（未設定なら既定値）
+	idLength, err := strconv.Atoi(os.Getenv("EVENT_ID_LENGTH"))
+	if err != nil {
+		idLength = 0
+	}
+	eventIDs, err := eventid.New(os.Getenv("EVENT_ID_ALPHABET"), idLength)
+	if err != nil {
+		fatal("Invalid event ID settings", err)
+	}
+
+	// 閲覧パスワード付きイベントのセッショントークン
+	// 鍵が未設定の場合は起動ごとに生成するため、再起動で発行済みのトークンは無効になる
+	sessionSecret := os.Getenv("EVENT_SESSION_SECRET")
+	if sessionSecret == "" {
+		slog.Warn("EVENT_SESSION_SECRET is not set; viewer sessions will not survive restarts")
+	}
+	sessionTTL, err := time.ParseDuration(os.Getenv("EVENT_SESSION_TTL"))
+	if err != nil {
+		sessionTTL = access.DefaultTTL
+	}
+
+	// ログイン後のアカウントのセッショントークン（EVENT_SESSION_SECRET から閲覧用とは別の鍵を導出する）
+	accountTTL, err := time.ParseDuration(os.Getenv("ACCOUNT_SESSION_TTL"))
+	if err != nil {
+		accountTTL = access.DefaultAccountTTL
+	}
+
+	// カレンダー購読（.ics）の URL に付けるトークン（同じく別の鍵を導出する）
+	calendarTTL, err := time.ParseDuration(os.Getenv("CALENDAR_TOKEN_TTL"))
+	if err != nil {
+		calendarTTL = access.DefaultCalendarTTL
+	}
+
+	// メールの送信方法（SMTP が未設定なら MAIL_DIR に .eml として保存し、それも未設定なら宛先と件名だけをログに出力する）
+	var mailer mail.Sender = mail.LogSender{}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		mailer = mail.FileSender{Dir: dir, From: os.Getenv("MAIL_FROM")}
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		mailer = mail.SMTPSender{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+	if _, ok := mailer.(mail.LogSender); ok {
+		slog.Warn("SMTP_HOST and MAIL_DIR are not set; emails are NOT being sent (only recipients and subjects are logged), so sign-in links and notifications will not reach anyone")
+	}
+
+	// 不正利用対策: リクエストの頻度・大きさとイベントの件数の上限
+	ipRate := envRate("RATE_LIMIT_IP", "300/1m")                  // IP アドレスごとの全リクエスト
+	createRate := envRate("RATE_LIMIT_CREATE", "20/1h")           // IP アドレスごとのイベント作成・ログインリンク送信
+	eventWriteRate := envRate("RATE_LIMIT_EVENT_WRITES", "60/1m") // イベントごとの回答の追加・更新
+	passwordRate := envRate("RATE_LIMIT_PASSWORD", "10/10m")      // IP アドレスごとの閲覧パスワードの試行
+	maxBody := int64(envInt("MAX_BODY_BYTES", 1<<20))
+	maxUpload := int64(envInt("MAX_UPLOAD_BYTES", 10<<20))
+
+	// Webhook の配信（開発中にローカルの受信先を使う場合は WEBHOOK_ALLOW_PRIVATE=true）
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE"))
+	dispatcher := webhooks.NewDispatcher(database, webhooks.Options{
+		AllowPrivate: allowPrivate,
+		Workers:      envInt("WEBHOOK_WORKERS", 4), // 同時に配信する数
+	})
 
 	// ハンドラーの初期化
-	h := handlers.NewHandler(database)
+	h := handlers.NewHandler(database, jobManager, handlers.Config{
+		OptimizeTimeout: optimizeTimeout,
+		Location:        timeslot.LoadLocation(os.Getenv("EVENT_TIMEZONE")),
+		EventIDs:        eventIDs,
+		Sessions:        access.NewSigner([]byte(sessionSecret), access.PurposeViewer, sessionTTL),
+		Accounts:        access.NewSigner([]byte(sessionSecret), access.PurposeAccount, accountTTL),
+		Calendars:       access.NewSigner([]byte(sessionSecret), access.PurposeCalendar, calendarTTL),
+		Mail:            mailer,
+		AppURL:          os.Getenv("FRONTEND_URL"),
+		MaxDates:        envInt("MAX_DATES_PER_EVENT", 400),
+		MaxPerformances: envInt("MAX_PERFORMANCES_PER_EVENT", 100),
+		MaxResponses:    envInt("MAX_RESPONSES_PER_EVENT", 500),
+		MaxSessions:     envInt("MAX_SESSIONS", 20),
+		Webhooks:        dispatcher,
+	})
+
+	// 回答の締め切りの確認（催促の送信・締め切り後の自動最適化）
+	deadlineInterval, err := time.ParseDuration(os.Getenv("DEADLINE_CHECK_INTERVAL"))
+	if err != nil {
+		deadlineInterval = time.Minute
+	}
+	h.StartDeadlineScheduler(deadlineInterval)
+
+	limitIP := middleware.RateLimit(middleware.NewLimiter(ipRate), middleware.ByIP)
+	limitCreate := middleware.RateLimit(middleware.NewLimiter(createRate), middleware.ByIP)
+	limitEventWrites := middleware.RateLimit(middleware.NewLimiter(eventWriteRate), middleware.ByEvent)
+	limitPassword := middleware.RateLimit(middleware.NewLimiter(passwordRate), middleware.ByIP)
+	limitUpload := middleware.BodyLimit(maxUpload)
 
 	// ルートの設定
 	api := router.Group("/api")
+	api.Use(limitIP, middleware.BodyLimit(maxBody))
 	{
+		auth := api.Group("/auth")
+		{
+			auth.POST("/login", limitCreate, h.RequestLogin)
+			auth.POST("/verify", h.VerifyLogin)
+		}
+		api.GET("/me", h.GetMe)
+		api.PUT("/me", h.UpdateMe)
+		api.PUT("/me/notifications", h.UpdateNotifications)
+
+		organizations := api.Group("/organizations")
+		{
+			organizations.POST("", limitCreate, h.CreateOrganization)
+			organizations.GET("", h.GetOrganizations)
+			organizations.GET("/:orgId", h.GetOrganization)
+			organizations.GET("/:orgId/events", h.GetOrganizationEvents)
+			organizations.POST("/:orgId/members", h.AddMember)
+			organizations.DELETE("/:orgId/members/:userId", h.RemoveMember)
+			organizations.GET("/:orgId/roster", h.GetRoster)
+			organizations.POST("/:orgId/roster", h.CreateRosterMember)
+			organizations.PUT("/:orgId/roster/:memberId", h.UpdateRosterMember)
+			organizations.DELETE("/:orgId/roster/:memberId", h.DeleteRosterMember)
+		}
+
 		events := api.Group("/events")
 		{
-			events.POST("", h.CreateEvent)
+			events.POST("", limitCreate, h.CreateEvent)
+			events.POST("/:id/session", limitPassword, h.CreateSession)
+
+			// カレンダーの購読 URL は token クエリにカレンダー購読用のトークンも使える
+			events.GET("/:id/responses/:responseId/schedule.ics", h.RequireCalendarViewer, h.ExportMemberScheduleICS)
+			events.GET("/:id/schedule.ics", h.RequireCalendarViewer, h.ExportScheduleICS)
+
+			// ここから下はパスワード付きイベントではセッショントークンが必要
+			events.Use(h.RequireViewer)
 			events.GET("/:id", h.GetEvent)
-			events.POST("/:id/responses", h.AddResponse)
+			events.POST("/:id/clone", limitCreate, h.CloneEvent)
+			events.GET("/:id/roster", h.GetEventRoster)
+			events.GET("/:id/dates/unanswered", h.GetUnansweredDates)
+			events.POST("/:id/responses", limitEventWrites, h.AddResponse)
+			events.POST("/:id/responses/prefill", limitUpload, h.PrefillAnswers)
 			events.GET("/:id/responses", h.GetResponses)
+			events.GET("/:id/responses/duplicates", h.GetDuplicateResponses)
+			events.GET("/:id/schedule", h.GetSchedule)
+			events.POST("/:id/calendar-token", h.CreateCalendarToken)
+			events.GET("/:id/feed", h.StreamEventFeed)
+			events.GET("/:id/exports/:table", h.ExportTable)
 			events.GET("/:id/optimal-schedule", h.SuggestOptimalSchedule)
 			events.GET("/:id/multi-optimal-schedule", h.SuggestOptimalMultiSessionSchedule)
+			events.GET("/:id/optimal-schedule/stream", h.StreamOptimalSchedule)
+			events.POST("/:id/optimizations", h.CreateOptimization)
+			events.GET("/:id/optimizations/:jobId", h.GetOptimization)
+			events.DELETE("/:id/optimizations/:jobId", h.CancelOptimization)
+
+			// イベントの設定・日程・既存の回答・確定した日程の変更は、団体のイベントではメンバーだけができる
+			organizer := events.Group("", h.RequireOrganizer)
+			{
+				organizer.PUT("/:id/roster", h.LinkEventRoster)
+				organizer.PUT("/:id/deadline", h.UpdateDeadline)
+				organizer.POST("/:id/reminders", h.SendReminders)
+				organizer.POST("/:id/dates", h.AddDates)
+				organizer.POST("/:id/dates/import", limitUpload, h.ImportDatesFromICS)
+				organizer.DELETE("/:id/dates/:dateId", h.DeleteDate)
+				organizer.POST("/:id/responses/import", limitEventWrites, limitUpload, h.ImportResponses)
+				organizer.PUT("/:id/responses/:responseId", limitEventWrites, h.UpdateResponse)
+				organizer.DELETE("/:id/responses/:responseId", limitEventWrites, h.DeleteResponse)
+				organizer.POST("/:id/responses/:responseId/merge", h.MergeResponses)
+				organizer.POST("/:id/responses/:responseId/prefill", limitUpload, h.PrefillResponseAnswers)
+				organizer.PUT("/:id/schedule", h.ConfirmSchedule)
+				organizer.POST("/:id/webhooks", h.CreateWebhook)
+				organizer.GET("/:id/webhooks", h.GetWebhooks)
+				organizer.DELETE("/:id/webhooks/:webhookId", h.DeleteWebhook)
+				organizer.GET("/:id/webhooks/:webhookId/deliveries", h.GetWebhookDeliveries)
+				organizer.POST("/:id/webhooks/:webhookId/test", h.TestWebhook)
+			}
 		}
+
+		templates := api.Group("/templates")
+		{
+			templates.POST("", h.CreateTemplate)
+			templates.GET("", h.GetTemplates)
+			templates.GET("/:templateId", h.GetTemplate)
+			templates.DELETE("/:templateId", h.DeleteTemplate)
+		}
+
 	}
 
 	// サーバーの起動
@@ -58,8 +288,47 @@ func main() {
 	if port == "" {
 		port = "8080" // デフォルトポート
 	}
-	log.Printf("Server running on port %s", port)
+	slog.Info("Server running", "port", port)
 	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal("Failed to start server", err)
+	}
+}
+
+// fatal はエラーを出力して終了します
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// envInt は環境変数の整数を返します（未設定・不正な値なら def）
+func envInt(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// envList は環境変数のカンマ区切りの値を返します（未設定なら nil）
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envRate は環境変数のレート制限の設定を返します（未設定なら def、"off" で制限なし）
+func envRate(name, def string) middleware.Rate {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value = def
+	}
+	rate, err := middleware.ParseRate(value)
+	if err != nil {
+		fatal("Invalid "+name, err)
 	}
+	return rate
 }
@@ -0,0 +1,184 @@
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultTTL はイベントの閲覧用セッショントークンの既定の有効期間です
+const DefaultTTL = 2 * time.Hour
+
+// DefaultAccountTTL はアカウントのセッショントークンの既定の有効期間です
+const DefaultAccountTTL = 30 * 24 * time.Hour
+
+// DefaultCalendarTTL はカレンダー購読用トークンの既定の有効期間です
+// カレンダーアプリは購読 URL を更新しないため、閲覧用トークンより長くします
+const DefaultCalendarTTL = 365 * 24 * time.Hour
+
+// パスワードの長さの範囲（bcrypt は 72 バイトまでしか使わない）
+const (
+	MinPasswordLength = 4
+	MaxPasswordLength = 72
+)
+
+var (
+	// ErrInvalidToken はトークンの形式か署名が正しくないことを表します
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken はトークンの有効期限が切れていることを表します
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// HashPassword は閲覧パスワードを bcrypt でハッシュ化します
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("password must be between %d and %d bytes", MinPasswordLength, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword は password が hash と一致するかを返します
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Purpose はトークンの用途です
+// 用途ごとに別の鍵で署名するため、ある用途のトークンは別の用途の Signer では検証できません
+type Purpose string
+
+const (
+	// PurposeViewer はイベントの閲覧用セッショントークンです
+	PurposeViewer Purpose = "viewer"
+	// PurposeAccount はアカウントのセッショントークンです
+	PurposeAccount Purpose = "account"
+	// PurposeCalendar はカレンダー購読（.ics）の URL に付けるトークンです
+	PurposeCalendar Purpose = "calendar"
+)
+
+// Signer はイベントの閲覧用・カレンダー購読用・アカウントのセッショントークンを発行・検証します
+// 閲覧用・カレンダー購読用のトークンは "有効期限（Unix 秒）.署名" の形式で、署名はイベントIDとパスワードのハッシュに
+// 結び付くため、別のイベントには使えず、パスワードを変えると無効になります
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSigner は secret から purpose 用に導出した鍵で署名する Signer を返します
+// secret が空の場合は起動ごとに乱数の鍵を使います（再起動で発行済みのトークンは無効になります）
+// ttl が 0 以下の場合は DefaultTTL を使います
+func NewSigner(secret []byte, purpose Purpose, ttl time.Duration) *Signer {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("schedule-app token:" + string(purpose)))
+	return &Signer{secret: mac.Sum(nil), ttl: ttl}
+}
+
+// Issue はイベントのセッショントークンと有効期限を返します
+func (s *Signer) Issue(eventID, passwordHash string, now time.Time) (string, time.Time) {
+	expires := now.Add(s.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + s.sign(eventID, passwordHash, exp), expires
+}
+
+// Verify はトークンがイベントに対して有効かを確認します
+func (s *Signer) Verify(token, eventID, passwordHash string, now time.Time) error {
+	exp, sig, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(eventID, passwordHash, exp))) {
+		return ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return ErrExpiredToken
+	}
+	return nil
+}
+
+// IssueUser はアカウントのセッショントークンと有効期限を返します
+// 閲覧用トークンと区別できるよう "u.ユーザーID.有効期限.署名" の形式です
+func (s *Signer) IssueUser(userID uint, now time.Time) (string, time.Time) {
+	expires := now.Add(s.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	sub := userSubject(userID)
+	return sub + "." + exp + "." + s.sign(sub, "", exp), expires
+}
+
+// VerifyUser はアカウントのセッショントークンを検証し、ユーザーIDを返します
+func (s *Signer) VerifyUser(token string, now time.Time) (uint, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != "u" {
+		return 0, ErrInvalidToken
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	sub := userSubject(uint(id))
+	if !hmac.Equal([]byte(parts[3]), []byte(s.sign(sub, "", parts[2]))) {
+		return 0, ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return 0, ErrExpiredToken
+	}
+	return uint(id), nil
+}
+
+// IsUserToken はトークンがアカウントのセッショントークンの形式かを返します
+func IsUserToken(token string) bool {
+	return strings.HasPrefix(token, "u.")
+}
+
+// userSubject はアカウントのトークンの署名対象です
+// イベントIDに "." は使えないため、閲覧用トークンの署名対象と重なりません
+func userSubject(userID uint) string {
+	return "u." + strconv.FormatUint(uint64(userID), 10)
+}
+
+// NewLoginToken はマジックリンク用のワンタイムトークンとその保存用ハッシュを返します
+func NewLoginToken() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashLoginToken(token)
+}
+
+// HashLoginToken はマジックリンクのトークンを保存用にハッシュ化します
+func HashLoginToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sign はトークンの署名を計算します
+func (s *Signer) sign(eventID, passwordHash, exp string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(eventID + "\n" + passwordHash + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -1,11 +1,13 @@
 package algorithm
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
 	"time"
 
+	"github.com/raie03/schedule-app/backend/internal/logging"
 	"github.com/raie03/schedule-app/backend/internal/models"
 )
 
@@ -19,54 +21,139 @@ import (
 // Schedule はパフォーマンスから日付へのマッピングを表します
 type Schedule map[uint]uint // performanceID -> dateID
 
+// Options は焼きなまし法のパラメータです
+type Options struct {
+	MaxIterations      int
+	InitialTemperature float64
+	CoolingRate        float64
+	MinTemperature     float64
+	MoveMix            MoveMix
+	// Unanswered は未回答（回答なしを含む）の扱いです
+	Unanswered UnansweredPolicy
+
+	// Progress が設定されていれば ProgressInterval 回ごとと終了時に呼び出されます
+	Progress         func(Progress)
+	ProgressInterval int
+}
+
+// UnansweredPolicy は未回答のメンバーを最適化でどう扱うかを表します
+type UnansweredPolicy string
+
+const (
+	// UnansweredOptimistic は未回答を参加可能とみなします
+	UnansweredOptimistic UnansweredPolicy = "optimistic"
+	// UnansweredPessimistic は未回答を参加不可とみなします
+	UnansweredPessimistic UnansweredPolicy = "pessimistic"
+	// UnansweredMaybe は未回答を未定（maybe）とみなします
+	UnansweredMaybe UnansweredPolicy = "maybe"
+)
+
+// ParseUnansweredPolicy は未回答の扱いの名前を検証します
+func ParseUnansweredPolicy(s string) (UnansweredPolicy, error) {
+	switch policy := UnansweredPolicy(s); policy {
+	case UnansweredOptimistic, UnansweredPessimistic, UnansweredMaybe:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unanswered must be optimistic, pessimistic or maybe: %q", s)
+}
+
+// Progress は焼きなまし法の途中経過です
+type Progress struct {
+	Iteration     int     `json:"iteration"`
+	MaxIterations int     `json:"max_iterations"`
+	Temperature   float64 `json:"temperature"`
+	Energy        float64 `json:"energy"`
+	BestEnergy    float64 `json:"best_energy"`
+}
+
+// DefaultOptions は既定のパラメータを返します
+func DefaultOptions() Options {
+	return Options{
+		MaxIterations:      10000,
+		InitialTemperature: 100.0,
+		CoolingRate:        0.99,
+		MinTemperature:     0.1,
+		MoveMix:            DefaultMoveMix(),
+		Unanswered:         UnansweredMaybe,
+		ProgressInterval:   100,
+	}
+}
+
+// Result は最適化の結果と統計情報です
+type Result struct {
+	Schedule   []models.ScoredOption
+	Energy     float64
+	Iterations int
+	MoveStats  map[MoveType]MoveStat
+	// Truncated はキャンセルまたは期限切れにより途中で打ち切られたことを表します
+	Truncated bool
+}
+
 // OptimizeSchedule はグローバル最適化アルゴリズムを使用して最適なスケジュールを生成します
-func OptimizeSchedule(allOptions []models.ScoredOption, perfCount int, dateCount int, users map[string]*models.UserData) []models.ScoredOption {
-	// オプションをマップに変換して高速なルックアップを可能にする
-	optionMap := make(map[string]models.ScoredOption)
-	for _, opt := range allOptions {
-		key := getOptionKey(opt.PerformanceID, opt.DateID)
-		optionMap[key] = opt
+// ctx がキャンセルされた、または期限を過ぎた場合はその時点での最良解と truncated=true を返します
+func OptimizeSchedule(ctx context.Context, allOptions []models.ScoredOption, perfCount int, users map[string]*models.UserData) ([]models.ScoredOption, bool) {
+	result := Optimize(ctx, allOptions, perfCount, users, DefaultOptions())
+	return result.Schedule, result.Truncated
+}
+
+// Optimize は指定したパラメータで最適化を行い、手の種類ごとの統計とともに結果を返します
+// ctx がキャンセルされた、または期限を過ぎた場合はその時点での最良解を返し、Truncated を立てます
+func Optimize(ctx context.Context, allOptions []models.ScoredOption, perfCount int, users map[string]*models.UserData, opts Options) Result {
+	if opts.MoveMix == nil || opts.MoveMix.total() == 0 {
+		opts.MoveMix = DefaultMoveMix()
 	}
 
-	// パフォーマンスIDのリスト
-	perfIDs := make([]uint, 0, perfCount)
-	for _, opt := range allOptions {
-		found := false
-		for _, id := range perfIDs {
-			if id == opt.PerformanceID {
-				found = true
-				break
-			}
-		}
-		if !found {
-			perfIDs = append(perfIDs, opt.PerformanceID)
-		}
+	// インデックス配列・ビット集合を事前計算して高速なルックアップを可能にする
+	pr := newProblem(allOptions, users, opts.Unanswered)
+	if len(pr.perfIDs) == 0 {
+		return Result{Schedule: []models.ScoredOption{}, MoveStats: map[MoveType]MoveStat{}}
 	}
 
 	// 初期解の生成（貪欲法）
 	initialSchedule := buildInitialSchedule(allOptions, perfCount)
+	initialAssign := make([]int, len(pr.perfIDs))
+	for p, perfID := range pr.perfIDs {
+		initialAssign[p] = pr.dateIndex[initialSchedule[perfID]]
+	}
 
 	// 焼きなまし法によるグローバル最適化
-	optimizedSchedule := simulatedAnnealing(initialSchedule, optionMap, perfIDs, users, 10000, 0.99)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	best, iterations, stats, truncated := simulatedAnnealing(ctx, pr, initialAssign, opts, rng)
 
 	// スケジュールをScoredOptionのリストに変換
-	result := make([]models.ScoredOption, 0, len(optimizedSchedule))
-	for perfID, dateID := range optimizedSchedule {
-		key := getOptionKey(perfID, dateID)
-		if opt, exists := optionMap[key]; exists {
-			// コンフリクトのリストを再計算
-			conflictingUsers := calculateConflictingUsers(optimizedSchedule, users, perfID, dateID)
-
-			// 複製して更新したオプションを作成
-			updatedOpt := opt
-			updatedOpt.ConflictCount = len(conflictingUsers)
-			updatedOpt.ConflictingUsers = conflictingUsers
-
-			result = append(result, updatedOpt)
+	schedule := make([]models.ScoredOption, 0, len(best.assign))
+	for p, d := range best.assign {
+		if !pr.hasOption[p][d] {
+			continue
 		}
+
+		// コンフリクトのリストを再計算
+		conflictingUsers := best.conflictingUsers(p)
+
+		// 複製して更新したオプションを作成
+		updatedOpt := pr.options[p][d]
+		updatedOpt.ConflictCount = len(conflictingUsers)
+		updatedOpt.ConflictingUsers = conflictingUsers
+
+		schedule = append(schedule, updatedOpt)
 	}
 
-	return result
+	// 近傍操作ごとの採用率はチューニング用に debug レベルでだけ出力する
+	logging.FromContext(ctx).Debug("annealing finished",
+		"performances", len(pr.perfIDs),
+		"iterations", iterations,
+		"energy", best.energy,
+		"truncated", truncated,
+		"moves", stats,
+	)
+
+	return Result{
+		Schedule:   schedule,
+		Energy:     best.energy,
+		Iterations: iterations,
+		MoveStats:  stats,
+		Truncated:  truncated,
+	}
 }
 
 // buildInitialSchedule は貪欲法を使用して初期スケジュールを構築します
@@ -120,221 +207,109 @@ func buildInitialSchedule(allOptions []models.ScoredOption, perfCount int) Sched
 }
 
 // simulatedAnnealing は焼きなまし法を使用してスケジュールを最適化します
-func simulatedAnnealing(initialSchedule Schedule, optionMap map[string]models.ScoredOption,
-	perfIDs []uint, users map[string]*models.UserData,
-	maxIterations int, coolingRate float64) Schedule {
-	// 乱数ジェネレータの初期化
-	rand.Seed(time.Now().UnixNano())
-
-	currentSchedule := copySchedule(initialSchedule)
-	bestSchedule := copySchedule(initialSchedule)
-
-	currentEnergy := calculateEnergy(currentSchedule, optionMap, users)
-	bestEnergy := currentEnergy
-
-	temperature := 100.0 // 初期温度
-
-	for iteration := 0; iteration < maxIterations && temperature > 0.1; iteration++ {
-		// 隣接解の生成: ランダムなパフォーマンスを選択し、異なる日付に移動
-		neighborSchedule := generateNeighbor(currentSchedule, perfIDs, optionMap)
+// 各ステップでは手を構成する移動についてエネルギー差分のみを評価します
+func simulatedAnnealing(ctx context.Context, pr *problem, initialAssign []int, opts Options,
+	rng *rand.Rand) (*annealState, int, map[MoveType]MoveStat, bool) {
+	current := newAnnealState(pr, initialAssign)
+	bestAssign := append([]int(nil), current.assign...)
+	bestEnergy := current.energy
+
+	stats := make(map[MoveType]MoveStat, len(moveTypes))
+	temperature := opts.InitialTemperature
+
+	reportProgress := func(iteration int) {
+		if opts.Progress != nil {
+			opts.Progress(Progress{
+				Iteration:     iteration,
+				MaxIterations: opts.MaxIterations,
+				Temperature:   temperature,
+				Energy:        current.energy,
+				BestEnergy:    bestEnergy,
+			})
+		}
+	}
 
-		// エネルギー（コスト）の計算 - 低いほど良い
-		neighborEnergy := calculateEnergy(neighborSchedule, optionMap, users)
+	iteration := 0
+	truncated := false
+	for ; iteration < opts.MaxIterations && temperature > opts.MinTemperature; iteration++ {
+		// キャンセル・期限切れの確認は一定間隔ごとに行う
+		if iteration%64 == 0 && ctx.Err() != nil {
+			truncated = true
+			break
+		}
+		if opts.ProgressInterval > 0 && iteration > 0 && iteration%opts.ProgressInterval == 0 {
+			reportProgress(iteration)
+		}
 
-		// 解の採用判定
-		if acceptSolution(currentEnergy, neighborEnergy, temperature) {
-			currentSchedule = copySchedule(neighborSchedule)
-			currentEnergy = neighborEnergy
+		// 隣接解の生成: 重みに従って手の種類を選び、移動列を作る
+		moveType := opts.MoveMix.pick(rng)
+		steps := generateMove(current, moveType, rng)
+		if steps != nil {
+			stat := stats[moveType]
+			stat.Proposed++
+
+			// エネルギー（コスト）の差分計算 - 低いほど良い
+			before := current.energy
+			delta, undo := current.applySteps(steps)
+
+			// 解の採用判定
+			if acceptSolution(before, before+delta, temperature, rng) {
+				stat.Accepted++
+				if delta < 0 {
+					stat.Improved++
+				}
 
-			// より良い解が見つかれば保存
-			if currentEnergy < bestEnergy {
-				bestSchedule = copySchedule(currentSchedule)
-				bestEnergy = currentEnergy
+				// より良い解が見つかれば保存
+				if current.energy < bestEnergy {
+					copy(bestAssign, current.assign)
+					bestEnergy = current.energy
+				}
+			} else {
+				current.revertSteps(undo)
+				current.energy = before
 			}
+
+			stats[moveType] = stat
 		}
 
 		// 温度の冷却
-		temperature *= coolingRate
+		temperature *= opts.CoolingRate
 	}
+	reportProgress(iteration)
 
-	return bestSchedule
-}
-
-// generateNeighbor はランダムなパフォーマンスを選んで異なる日付に割り当てます
-func generateNeighbor(schedule Schedule, perfIDs []uint, optionMap map[string]models.ScoredOption) Schedule {
-	neighbor := copySchedule(schedule)
-
-	// ランダムにパフォーマンスを選択
-	perfIndex := rand.Intn(len(perfIDs))
-	perfID := perfIDs[perfIndex]
-
-	// そのパフォーマンスに有効な日付の候補を取得
-	validDates := make([]uint, 0)
-	for key := range optionMap {
-		opt := optionMap[key]
-		if opt.PerformanceID == perfID {
-			validDates = append(validDates, opt.DateID)
+	for moveType, stat := range stats {
+		if stat.Proposed > 0 {
+			stat.AcceptanceRate = float64(stat.Accepted) / float64(stat.Proposed)
 		}
+		stats[moveType] = stat
 	}
 
-	if len(validDates) > 0 {
-		// ランダムに新しい日付を選択（現在と同じ可能性もあり）
-		dateIndex := rand.Intn(len(validDates))
-		neighbor[perfID] = validDates[dateIndex]
-	}
-
-	return neighbor
+	return newAnnealState(pr, bestAssign), iteration, stats, truncated
 }
 
-// calculateConflictingUsers は特定のパフォーマンスと日付の組み合わせについて
-// コンフリクトするユーザーのリストを計算します
-func calculateConflictingUsers(schedule Schedule, users map[string]*models.UserData, targetPerfID, targetDateID uint) []string {
-	// この日付に割り当てられたパフォーマンスを特定
-	datePerformances := make([]uint, 0)
-	for perfID, dateID := range schedule {
-		if dateID == targetDateID {
-			datePerformances = append(datePerformances, perfID)
-		}
-	}
+// conflictingUsers はパフォーマンス p の割り当て日付でコンフリクトするユーザーのリストを返します
+func (s *annealState) conflictingUsers(p int) []string {
+	d := s.assign[p]
 
 	// 単一のパフォーマンスしかないならコンフリクトはない
-	if len(datePerformances) <= 1 {
+	if s.dateLoad[d] <= 1 {
 		return []string{}
 	}
 
-	// コンフリクトするユーザーを検出
+	// この日に参加可能で、同日の他のパフォーマンスにも参加するユーザーを検出
+	userCount := len(s.pr.userNames)
 	conflictingUsers := make([]string, 0)
-	for userName, userData := range users {
-		// このユーザーが対象のパフォーマンスに参加するか
-		if !userData.Performances[targetPerfID] {
-			continue
-		}
-
-		// この日に参加可能か
-		availability := userData.Availability[targetDateID]
-		if availability != "available" && availability != "maybe" {
-			continue
-		}
-
-		// 同日の他のパフォーマンスにも参加するか
-		conflictDetected := false
-		for _, otherPerfID := range datePerformances {
-			if otherPerfID != targetPerfID && userData.Performances[otherPerfID] {
-				conflictDetected = true
-				break
-			}
-		}
-
-		if conflictDetected {
-			conflictingUsers = append(conflictingUsers, userName)
+	for _, u := range s.pr.memberList[p] {
+		if s.memberLoad[d*userCount+u] >= 2 && s.pr.dateAttend[d].has(u) {
+			conflictingUsers = append(conflictingUsers, s.pr.userNames[u])
 		}
 	}
 
 	return conflictingUsers
 }
 
-// calculateEnergy はスケジュールの「エネルギー」（コスト）を計算します
-// 低いほど良いスケジュールを意味します
-func calculateEnergy(schedule Schedule, optionMap map[string]models.ScoredOption, users map[string]*models.UserData) float64 {
-	// 日付ごとに割り当てられたパフォーマンスを追跡
-	dateToPerfs := make(map[uint][]uint)
-	for perfID, dateID := range schedule {
-		dateToPerfs[dateID] = append(dateToPerfs[dateID], perfID)
-	}
-
-	// 実際のコンフリクト数
-	totalConflicts := 0.0
-	conflictingUsers := make(map[string]bool)
-
-	// 参加可能人数
-	totalAvailable := 0.0
-	totalUnavailable := 0.0
-
-	// 各パフォーマンスとその日付について
-	for perfID, dateID := range schedule {
-		// この組み合わせの参加可能人数を取得
-		key := getOptionKey(perfID, dateID)
-		if opt, exists := optionMap[key]; exists {
-			// 参加可能人数（多いほど良い → 負にして最小化問題に）
-			totalAvailable -= float64(opt.AvailableCount) + (float64(opt.MaybeCount) * 0.5)
-
-			// 参加不可人数（多いほど悪い → そのままプラスで最小化問題に）
-			// 必要に応じてコメントアウトを解除
-			totalUnavailable += float64(opt.UnavailableCount) * 20
-		}
-
-		// コンフリクトの計算: 同じ日に複数のパフォーマンスに参加するユーザー
-		if perfs, exists := dateToPerfs[dateID]; exists && len(perfs) > 1 {
-			for userName, userData := range users {
-				// このユーザーが現在のパフォーマンスに参加するか
-				if !userData.Performances[perfID] {
-					continue
-				}
-
-				// この日に参加可能か
-				availability := userData.Availability[dateID]
-				if availability != "available" && availability != "maybe" {
-					continue
-				}
-
-				// 同日の他のパフォーマンスにも参加するか
-				for _, otherPerfID := range perfs {
-					if otherPerfID != perfID && userData.Performances[otherPerfID] {
-						// まだカウントしていないユーザーのみ数える
-						if !conflictingUsers[userName] {
-							totalConflicts += 1.0
-							conflictingUsers[userName] = true
-						}
-						break
-					}
-				}
-			}
-		}
-	}
-
-	// 日付の重複にペナルティを加える
-	dateOverlapPenalty := 0.0
-	// samePerfCount := 0.0
-	for _, perfs := range dateToPerfs {
-		if len(perfs) > 1 {
-			// 日付あたりのパフォーマンス数が多いほど大きなペナルティ
-			dateOverlapPenalty += math.Pow(float64(len(perfs)-1), 1.5) * 2.0
-		}
-	}
-
-	// 同じパフォーマンスの複数の練習が同じ日に行われることへの強いペナルティ
-	samePerformancePenalty := 0.0
-
-	// 各日付ごとに、同じ元パフォーマンスIDが複数割り当てられているか確認
-	for _, perfs := range dateToPerfs {
-		// 元の演目ID (perfID/100) ごとのカウント
-		origPerfCounts := make(map[uint]int)
-
-		for _, perfID := range perfs {
-			origPerfID := perfID / 100 // 元の演目ID
-			origPerfCounts[origPerfID]++
-		}
-
-		// 同じ演目が同じ日に複数回練習が割り当てられている場合、大きなペナルティ
-		for _, count := range origPerfCounts {
-			if count > 1 {
-				// より強いペナルティを加える（1つの同じ演目につき50ポイント）
-				samePerformancePenalty += float64(count * count * 50)
-			}
-		}
-	}
-
-	// 総合的なエネルギー計算
-	// - コンフリクト: 大きな重みで
-	// - 参加可能人数: 負の値として
-	// - 参加不可人数: プラスとして
-	// - 日付重複: ペナルティとして
-	// - 同じパフォーマンス練習の同日設定: 非常に大きなペナルティ
-	return (totalConflicts * 12.0) + totalAvailable + totalUnavailable + dateOverlapPenalty + samePerformancePenalty
-}
-
 // acceptSolution はエネルギーの差と温度に基づいて新しい解を受け入れるかを判定します
-func acceptSolution(currentEnergy, newEnergy, temperature float64) bool {
+func acceptSolution(currentEnergy, newEnergy, temperature float64, rng *rand.Rand) bool {
 	// より良い解は常に受け入れる
 	if newEnergy < currentEnergy {
 		return true
@@ -343,21 +318,7 @@ func acceptSolution(currentEnergy, newEnergy, temperature float64) bool {
 	// 確率的に悪い解も受け入れる（温度が高いほど受け入れやすい）
 	delta := newEnergy - currentEnergy
 	probability := math.Exp(-delta / temperature)
-	return rand.Float64() < probability
-}
-
-// copySchedule はスケジュールの深いコピーを作成します
-func copySchedule(schedule Schedule) Schedule {
-	copy := make(Schedule, len(schedule))
-	for k, v := range schedule {
-		copy[k] = v
-	}
-	return copy
-}
-
-// getOptionKey はパフォーマンスIDと日付IDからルックアップキーを生成します
-func getOptionKey(perfID, dateID uint) string {
-	return fmt.Sprintf("%d-%d", perfID, dateID)
+	return rng.Float64() < probability
 }
 
 // ExpandPerformancesForMultipleSessions はパフォーマンスを練習回数分に複製します
@@ -381,13 +342,20 @@ func ExpandPerformancesForMultipleSessions(originalPerfs []models.Performance, s
 }
 
 // OptimizeScheduleWithMultipleSessions は練習回数分に拡張したパフォーマンスに対して最適化を行います
-func OptimizeScheduleWithMultipleSessions(allOptions []models.ScoredOption,
-	origPerfCount int, dateCount int,
-	sessionCount int,
-	users map[string]*models.UserData) []models.ScoredOption {
+func OptimizeScheduleWithMultipleSessions(ctx context.Context, allOptions []models.ScoredOption,
+	origPerfCount int, sessionCount int,
+	users map[string]*models.UserData) ([]models.ScoredOption, bool) {
 	// 拡張された数のパフォーマンス（元の数 × セッション数）
 	expandedPerfCount := origPerfCount * sessionCount
 
 	// 通常の最適化を実行（拡張されたパフォーマンス数を使用）
-	return OptimizeSchedule(allOptions, expandedPerfCount, dateCount, users)
+	return OptimizeSchedule(ctx, allOptions, expandedPerfCount, users)
+}
+
+// OptimizeWithMultipleSessions は練習回数分に拡張したパフォーマンスに対して、
+// 指定したパラメータで最適化を行います
+func OptimizeWithMultipleSessions(ctx context.Context, allOptions []models.ScoredOption,
+	origPerfCount int, sessionCount int,
+	users map[string]*models.UserData, opts Options) Result {
+	return Optimize(ctx, allOptions, origPerfCount*sessionCount, users, opts)
 }
@@ -0,0 +1,267 @@
+package algorithm
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+)
+
+// bitset はメンバー集合を表すビット集合です
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (uint(i) % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+// forEach は立っているビットのインデックスごとに fn を呼び出します
+func (b bitset) forEach(fn func(i int)) {
+	for w, word := range b {
+		for word != 0 {
+			tz := bits.TrailingZeros64(word)
+			fn(w*64 + tz)
+			word &= word - 1
+		}
+	}
+}
+
+// problem は焼きなまし法で使う事前計算済みのインデックス配列を保持します
+// パフォーマンス・日付・ユーザーはすべて 0 始まりのインデックスで扱います
+type problem struct {
+	perfIDs   []uint
+	dateIDs   []uint
+	perfIndex map[uint]int
+	dateIndex map[uint]int
+	userNames []string
+
+	// options[p][d] は元の ScoredOption（存在しない組み合わせは hasOption=false）
+	options   [][]models.ScoredOption
+	hasOption [][]bool
+	// optionEnergy[p][d] は参加可能人数・参加不可人数によるエネルギー項
+	optionEnergy [][]float64
+	// validDates[p] はパフォーマンス p に割り当て可能な日付インデックス
+	validDates [][]int
+
+	// perfMembers[p] はパフォーマンス p に参加するユーザーの集合
+	perfMembers []bitset
+	// memberList[p] は perfMembers[p] を展開したユーザーインデックスの配列
+	memberList [][]int
+	// dateAttend[d] は日付 d に参加可能（available / maybe）なユーザーの集合
+	dateAttend []bitset
+
+	// group[p] は元の演目グループ（perfID/100）のインデックス
+	group      []int
+	groupCount int
+	// groupPerfs[g] は演目グループ g に属するパフォーマンスインデックス
+	groupPerfs [][]int
+	// sessionGroups は複数セッションを持つ演目グループのインデックス
+	sessionGroups []int
+
+	// dateOrder は日付インデックスを開始日時の順に並べたもの
+	// 日時として解釈できない日付は最後に日付ID順で並べる
+	dateOrder []int
+	// datePos[d] は dateOrder における日付 d の位置
+	datePos []int
+}
+
+// newProblem は ScoredOption とユーザーデータからインデックスを構築します
+// 未回答のメンバーは policy に従って参加可能人数・コンフリクトに数えます
+func newProblem(allOptions []models.ScoredOption, users map[string]*models.UserData, policy UnansweredPolicy) *problem {
+	pr := &problem{
+		perfIndex: make(map[uint]int),
+		dateIndex: make(map[uint]int),
+	}
+
+	var dateValues []string
+	for _, opt := range allOptions {
+		if _, ok := pr.perfIndex[opt.PerformanceID]; !ok {
+			pr.perfIndex[opt.PerformanceID] = len(pr.perfIDs)
+			pr.perfIDs = append(pr.perfIDs, opt.PerformanceID)
+		}
+		if _, ok := pr.dateIndex[opt.DateID]; !ok {
+			pr.dateIndex[opt.DateID] = len(pr.dateIDs)
+			pr.dateIDs = append(pr.dateIDs, opt.DateID)
+			dateValues = append(dateValues, opt.DateValue)
+		}
+	}
+
+	perfCount := len(pr.perfIDs)
+	dateCount := len(pr.dateIDs)
+
+	pr.options = make([][]models.ScoredOption, perfCount)
+	pr.hasOption = make([][]bool, perfCount)
+	pr.optionEnergy = make([][]float64, perfCount)
+	pr.validDates = make([][]int, perfCount)
+	for p := 0; p < perfCount; p++ {
+		pr.options[p] = make([]models.ScoredOption, dateCount)
+		pr.hasOption[p] = make([]bool, dateCount)
+		pr.optionEnergy[p] = make([]float64, dateCount)
+	}
+
+	for _, opt := range allOptions {
+		p := pr.perfIndex[opt.PerformanceID]
+		d := pr.dateIndex[opt.DateID]
+		if !pr.hasOption[p][d] {
+			pr.validDates[p] = append(pr.validDates[p], d)
+		}
+		pr.options[p][d] = opt
+		pr.hasOption[p][d] = true
+		pr.optionEnergy[p][d] = optionEnergy(opt, policy)
+	}
+
+	// ユーザーのビット集合を構築
+	pr.userNames = make([]string, 0, len(users))
+	for name := range users {
+		pr.userNames = append(pr.userNames, name)
+	}
+	sort.Strings(pr.userNames)
+
+	userCount := len(pr.userNames)
+	pr.perfMembers = make([]bitset, perfCount)
+	pr.memberList = make([][]int, perfCount)
+	for p := range pr.perfMembers {
+		pr.perfMembers[p] = newBitset(userCount)
+	}
+	pr.dateAttend = make([]bitset, dateCount)
+	for d := range pr.dateAttend {
+		pr.dateAttend[d] = newBitset(userCount)
+	}
+
+	for u, name := range pr.userNames {
+		userData := users[name]
+		for perfID, joined := range userData.Performances {
+			if p, ok := pr.perfIndex[perfID]; ok && joined {
+				pr.perfMembers[p].set(u)
+			}
+		}
+		for d, dateID := range pr.dateIDs {
+			if attends(userData.Availability[dateID], policy) {
+				pr.dateAttend[d].set(u)
+			}
+		}
+	}
+
+	for p, members := range pr.perfMembers {
+		members.forEach(func(u int) {
+			pr.memberList[p] = append(pr.memberList[p], u)
+		})
+	}
+
+	// 元の演目グループ（perfID/100）をインデックス化
+	groupIndex := make(map[uint]int)
+	pr.group = make([]int, perfCount)
+	for p, perfID := range pr.perfIDs {
+		origPerfID := perfID / 100
+		g, ok := groupIndex[origPerfID]
+		if !ok {
+			g = len(groupIndex)
+			groupIndex[origPerfID] = g
+		}
+		pr.group[p] = g
+	}
+	pr.groupCount = len(groupIndex)
+
+	pr.groupPerfs = make([][]int, pr.groupCount)
+	for p, g := range pr.group {
+		pr.groupPerfs[g] = append(pr.groupPerfs[g], p)
+	}
+	for g, perfs := range pr.groupPerfs {
+		if len(perfs) > 1 {
+			pr.sessionGroups = append(pr.sessionGroups, g)
+		}
+	}
+
+	// 時系列順の日付インデックス
+	// 日付は後から追加できるため、ID ではなく Date.Value の開始日時で並べる
+	// （どの日付も同じタイムゾーンで解釈するので、順序だけなら UTC で十分）
+	starts := make([]time.Time, dateCount)
+	parsed := make([]bool, dateCount)
+	for d, value := range dateValues {
+		if slot, err := timeslot.Parse(value, time.UTC); err == nil {
+			starts[d] = slot.Start
+			parsed[d] = true
+		}
+	}
+	pr.dateOrder = make([]int, dateCount)
+	for d := range pr.dateOrder {
+		pr.dateOrder[d] = d
+	}
+	sort.Slice(pr.dateOrder, func(i, j int) bool {
+		a, b := pr.dateOrder[i], pr.dateOrder[j]
+		if parsed[a] != parsed[b] {
+			return parsed[a]
+		}
+		if parsed[a] && !starts[a].Equal(starts[b]) {
+			return starts[a].Before(starts[b])
+		}
+		return pr.dateIDs[a] < pr.dateIDs[b]
+	})
+	pr.datePos = make([]int, dateCount)
+	for pos, d := range pr.dateOrder {
+		pr.datePos[d] = pos
+	}
+
+	return pr
+}
+
+// attends はその日に参加できるとみなすかを返します
+func attends(status models.AnswerStatus, policy UnansweredPolicy) bool {
+	switch status {
+	case models.StatusAvailable, models.StatusMaybe:
+		return true
+	case models.StatusUnavailable:
+		return false
+	default:
+		return policy != UnansweredPessimistic
+	}
+}
+
+// optionEnergy は単一の組み合わせが持つエネルギー項を返します
+func optionEnergy(opt models.ScoredOption, policy UnansweredPolicy) float64 {
+	available := float64(opt.AvailableCount)
+	maybe := float64(opt.MaybeCount)
+	unavailable := float64(opt.UnavailableCount)
+
+	// 未回答は設定に応じて参加可能・参加不可・未定のいずれかとして数える
+	switch policy {
+	case UnansweredOptimistic:
+		available += float64(opt.UnansweredCount)
+	case UnansweredPessimistic:
+		unavailable += float64(opt.UnansweredCount)
+	default:
+		maybe += float64(opt.UnansweredCount)
+	}
+
+	// 参加可能人数（多いほど良い → 負にして最小化問題に）
+	// 参加不可人数（多いほど悪い → そのままプラスで最小化問題に）
+	return -(available + maybe*0.5) + unavailable*20
+}
+
+// overlapPenalty は同じ日付に n 個のパフォーマンスがある場合のペナルティです
+func overlapPenalty(n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	// 日付あたりのパフォーマンス数が多いほど大きなペナルティ
+	return math.Pow(float64(n-1), 1.5) * 2.0
+}
+
+// samePerfPenalty は同じ演目が同じ日付に n 回割り当てられた場合のペナルティです
+func samePerfPenalty(n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	// 1つの同じ演目につき n*n*50 ポイント
+	return float64(n * n * 50)
+}
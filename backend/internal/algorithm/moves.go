@@ -0,0 +1,259 @@
+package algorithm
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// MoveType は近傍生成の手の種類を表します
+type MoveType string
+
+const (
+	// MoveRelocate はパフォーマンス1つを別の日付に移動します
+	MoveRelocate MoveType = "relocate"
+	// MoveSwap は2つのパフォーマンスの日付を入れ替えます
+	MoveSwap MoveType = "swap"
+	// MoveSessionShift は同じ演目の全セッションを前後の日付にずらします
+	MoveSessionShift MoveType = "session_shift"
+	// MoveChain はパフォーマンスを移動し、移動先にいた別のパフォーマンスを玉突きで移動します
+	MoveChain MoveType = "chain"
+)
+
+// moveTypes は MoveMix の重みを参照する順序です
+var moveTypes = []MoveType{MoveRelocate, MoveSwap, MoveSessionShift, MoveChain}
+
+// MoveMix は各手を選ぶ相対的な重みです（0 ならその手を使わない）
+type MoveMix map[MoveType]float64
+
+// DefaultMoveMix は既定の手の配分を返します
+func DefaultMoveMix() MoveMix {
+	return MoveMix{
+		MoveRelocate:     4,
+		MoveSwap:         3,
+		MoveSessionShift: 1,
+		MoveChain:        2,
+	}
+}
+
+// ParseMoveMix は "relocate:4,swap:2" 形式の文字列を MoveMix に変換します
+func ParseMoveMix(s string) (MoveMix, error) {
+	mix := make(MoveMix)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, weightStr, found := strings.Cut(part, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid move weight %q", part)
+		}
+
+		moveType := MoveType(strings.TrimSpace(name))
+		if !moveType.valid() {
+			return nil, fmt.Errorf("unknown move type %q", moveType)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for %q", moveType)
+		}
+		mix[moveType] = weight
+	}
+
+	if mix.total() == 0 {
+		return nil, fmt.Errorf("move mix must have at least one positive weight")
+	}
+	return mix, nil
+}
+
+func (t MoveType) valid() bool {
+	for _, moveType := range moveTypes {
+		if t == moveType {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MoveMix) total() float64 {
+	total := 0.0
+	for _, moveType := range moveTypes {
+		total += m[moveType]
+	}
+	return total
+}
+
+// pick は重みに従って手の種類をランダムに選びます
+func (m MoveMix) pick(rng *rand.Rand) MoveType {
+	r := rng.Float64() * m.total()
+	for _, moveType := range moveTypes {
+		r -= m[moveType]
+		if r < 0 {
+			return moveType
+		}
+	}
+	return MoveRelocate
+}
+
+// MoveStat は手の種類ごとの試行・採用数です
+type MoveStat struct {
+	Proposed       int     `json:"proposed"`
+	Accepted       int     `json:"accepted"`
+	Improved       int     `json:"improved"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+}
+
+// step は単一パフォーマンスの移動です
+type step struct {
+	perf int
+	to   int
+}
+
+// generateMove は指定された種類の手を構成する移動列を生成します
+// 有効な手が作れない場合は nil を返します
+func generateMove(s *annealState, moveType MoveType, rng *rand.Rand) []step {
+	switch moveType {
+	case MoveSwap:
+		return generateSwap(s, rng)
+	case MoveSessionShift:
+		return generateSessionShift(s, rng)
+	case MoveChain:
+		return generateChain(s, rng)
+	default:
+		return generateRelocate(s, rng)
+	}
+}
+
+// generateRelocate はランダムなパフォーマンスを現在とは別の有効な日付に移動します
+// 何も変わらない手を試行・採用に数えないよう、現在の日付は選びません
+func generateRelocate(s *annealState, rng *rand.Rand) []step {
+	p := rng.Intn(len(s.pr.perfIDs))
+	to, ok := otherValidDate(s.pr, p, s.assign[p], rng)
+	if !ok {
+		return nil
+	}
+	return []step{{perf: p, to: to}}
+}
+
+// otherValidDate はパフォーマンス p の有効な日付のうち except 以外を1つランダムに選びます
+func otherValidDate(pr *problem, p, except int, rng *rand.Rand) (int, bool) {
+	validDates := pr.validDates[p]
+	if !pr.hasOption[p][except] {
+		if len(validDates) == 0 {
+			return 0, false
+		}
+		return validDates[rng.Intn(len(validDates))], true
+	}
+	if len(validDates) < 2 {
+		return 0, false
+	}
+
+	// 末尾以外から選び、except を引いた場合は末尾で置き換える（末尾が except なら末尾以外に except はない）
+	last := len(validDates) - 1
+	d := validDates[rng.Intn(last)]
+	if d == except {
+		d = validDates[last]
+	}
+	return d, true
+}
+
+// generateSwap は異なる日付にある2つのパフォーマンスの日付を入れ替えます
+func generateSwap(s *annealState, rng *rand.Rand) []step {
+	perfCount := len(s.pr.perfIDs)
+	if perfCount < 2 {
+		return nil
+	}
+
+	p := rng.Intn(perfCount)
+	q := rng.Intn(perfCount - 1)
+	if q >= p {
+		q++
+	}
+
+	dp, dq := s.assign[p], s.assign[q]
+	if dp == dq || !s.pr.hasOption[p][dq] || !s.pr.hasOption[q][dp] {
+		return nil
+	}
+	return []step{{perf: p, to: dq}, {perf: q, to: dp}}
+}
+
+// generateSessionShift は同じ演目の全セッションを時系列で前後1つの日付にずらします
+func generateSessionShift(s *annealState, rng *rand.Rand) []step {
+	pr := s.pr
+	if len(pr.sessionGroups) == 0 {
+		return nil
+	}
+
+	perfs := pr.groupPerfs[pr.sessionGroups[rng.Intn(len(pr.sessionGroups))]]
+	dir := 1
+	if rng.Intn(2) == 0 {
+		dir = -1
+	}
+
+	steps := make([]step, 0, len(perfs))
+	for _, p := range perfs {
+		pos := pr.datePos[s.assign[p]] + dir
+		if pos < 0 || pos >= len(pr.dateOrder) {
+			return nil
+		}
+		to := pr.dateOrder[pos]
+		if !pr.hasOption[p][to] {
+			return nil
+		}
+		steps = append(steps, step{perf: p, to: to})
+	}
+	return steps
+}
+
+// generateChain はパフォーマンスを別の日付に移動し、
+// 移動先の日付にいた別のパフォーマンスをさらに別の日付へ玉突きで移動します
+func generateChain(s *annealState, rng *rand.Rand) []step {
+	first := generateRelocate(s, rng)
+	if first == nil {
+		return nil
+	}
+
+	to := first[0].to
+
+	// 移動先の日付にいるパフォーマンスを1つ選ぶ
+	var occupants []int
+	for q, d := range s.assign {
+		if d == to {
+			occupants = append(occupants, q)
+		}
+	}
+	if len(occupants) == 0 {
+		return first
+	}
+
+	q := occupants[rng.Intn(len(occupants))]
+	next, ok := otherValidDate(s.pr, q, to, rng)
+	if !ok {
+		return first
+	}
+	return append(first, step{perf: q, to: next})
+}
+
+// applySteps は移動列を順に適用し、エネルギー差分の合計と元に戻すための移動列を返します
+func (s *annealState) applySteps(steps []step) (float64, []step) {
+	total := 0.0
+	undo := make([]step, 0, len(steps))
+	for _, st := range steps {
+		undo = append(undo, step{perf: st.perf, to: s.assign[st.perf]})
+		delta := s.moveDelta(st.perf, st.to)
+		s.applyMove(st.perf, st.to, delta)
+		total += delta
+	}
+	return total, undo
+}
+
+// revertSteps は applySteps が返した移動列で状態を元に戻します
+func (s *annealState) revertSteps(undo []step) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		st := undo[i]
+		s.applyMove(st.perf, st.to, s.moveDelta(st.perf, st.to))
+	}
+}
@@ -0,0 +1,66 @@
+package algorithm
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+func TestDateOrderFollowsDateValues(t *testing.T) {
+	// 日付ID 1 → 5/20、2 → 5/6、3 → 5/13（後から前の日付を追加した場合）
+	values := map[uint]string{1: "2025-05-20 18:00-21:00", 2: "2025-05-06 18:00-21:00", 3: "2025-05-13 18:00-21:00"}
+	var options []models.ScoredOption
+	for _, perfID := range []uint{101, 102} {
+		for dateID := uint(1); dateID <= 3; dateID++ {
+			options = append(options, models.ScoredOption{PerformanceID: perfID, DateID: dateID, DateValue: values[dateID]})
+		}
+	}
+	pr := newProblem(options, map[string]*models.UserData{}, UnansweredMaybe)
+
+	var got []uint
+	for _, d := range pr.dateOrder {
+		got = append(got, pr.dateIDs[d])
+	}
+	want := []uint{2, 3, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("date order = %v, want %v", got, want)
+		}
+	}
+
+	// 5/13 にいる2つのセッションを後ろにずらすと、ID 順の隣（なし）ではなく 5/20 に移る
+	s := newAnnealState(pr, []int{pr.dateIndex[3], pr.dateIndex[3]})
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		steps := generateSessionShift(s, rng)
+		if steps == nil {
+			continue
+		}
+		for _, st := range steps {
+			if id := pr.dateIDs[st.to]; id != 1 && id != 2 {
+				t.Fatalf("session shift from 5/13 moved to date %d", id)
+			}
+		}
+	}
+}
+
+func TestRelocateSkipsCurrentDate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	options, users := randomInstance(rng, 5, 2, 4, 10)
+	pr := newProblem(options, users, UnansweredMaybe)
+	s := newAnnealState(pr, initialAssignment(pr, options))
+
+	for i := 0; i < 2000; i++ {
+		for _, moveType := range []MoveType{MoveRelocate, MoveChain} {
+			for _, st := range generateMove(s, moveType, rng) {
+				if st.to == s.assign[st.perf] {
+					t.Fatalf("%s proposed moving performance %d to its current date", moveType, pr.perfIDs[st.perf])
+				}
+				if !pr.hasOption[st.perf][st.to] {
+					t.Fatalf("%s proposed an invalid date for performance %d", moveType, pr.perfIDs[st.perf])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package algorithm
+
+// annealState は焼きなまし法の現在の割り当てと、差分評価に必要な集計値を保持します
+type annealState struct {
+	pr *problem
+
+	// assign[p] はパフォーマンス p に割り当てられた日付インデックス
+	assign []int
+
+	// dateLoad[d] は日付 d に割り当てられたパフォーマンス数
+	dateLoad []int
+	// memberLoad[d*userCount+u] は日付 d でユーザー u が参加するパフォーマンス数
+	memberLoad []int32
+	// groupLoad[d*groupCount+g] は日付 d に割り当てられた演目グループ g の練習数
+	groupLoad []int32
+	// conflictDates[u] はユーザー u がコンフリクトしている日付の数
+	conflictDates []int32
+	// conflictUsers はコンフリクトしているユーザー数
+	conflictUsers int
+
+	optionSum float64
+	energy    float64
+}
+
+// newAnnealState は初期割り当てから集計値を計算します
+func newAnnealState(pr *problem, assign []int) *annealState {
+	userCount := len(pr.userNames)
+	dateCount := len(pr.dateIDs)
+
+	s := &annealState{
+		pr:            pr,
+		assign:        append([]int(nil), assign...),
+		dateLoad:      make([]int, dateCount),
+		memberLoad:    make([]int32, dateCount*userCount),
+		groupLoad:     make([]int32, dateCount*pr.groupCount),
+		conflictDates: make([]int32, userCount),
+	}
+
+	for p, d := range s.assign {
+		s.dateLoad[d]++
+		s.groupLoad[d*pr.groupCount+pr.group[p]]++
+		s.optionSum += pr.optionEnergy[p][d]
+		for _, u := range pr.memberList[p] {
+			s.memberLoad[d*userCount+u]++
+		}
+	}
+
+	for d := 0; d < dateCount; d++ {
+		for u := 0; u < userCount; u++ {
+			if s.memberLoad[d*userCount+u] >= 2 && pr.dateAttend[d].has(u) {
+				s.conflictDates[u]++
+			}
+		}
+	}
+	for _, n := range s.conflictDates {
+		if n > 0 {
+			s.conflictUsers++
+		}
+	}
+
+	s.energy = s.fullEnergy()
+	return s
+}
+
+// fullEnergy は集計値からエネルギー全体を計算します
+func (s *annealState) fullEnergy() float64 {
+	energy := float64(s.conflictUsers)*12.0 + s.optionSum
+	for _, n := range s.dateLoad {
+		energy += overlapPenalty(n)
+	}
+	for _, n := range s.groupLoad {
+		energy += samePerfPenalty(int(n))
+	}
+	return energy
+}
+
+// moveDelta はパフォーマンス p を日付 to に移動した場合のエネルギー差分を
+// 状態を変更せずに計算します
+func (s *annealState) moveDelta(p, to int) float64 {
+	from := s.assign[p]
+	if from == to {
+		return 0
+	}
+
+	pr := s.pr
+	userCount := len(pr.userNames)
+
+	delta := pr.optionEnergy[p][to] - pr.optionEnergy[p][from]
+
+	// 日付重複ペナルティ
+	delta += overlapPenalty(s.dateLoad[from]-1) - overlapPenalty(s.dateLoad[from])
+	delta += overlapPenalty(s.dateLoad[to]+1) - overlapPenalty(s.dateLoad[to])
+
+	// 同一演目ペナルティ
+	g := pr.group[p]
+	fromGroup := int(s.groupLoad[from*pr.groupCount+g])
+	toGroup := int(s.groupLoad[to*pr.groupCount+g])
+	delta += samePerfPenalty(fromGroup-1) - samePerfPenalty(fromGroup)
+	delta += samePerfPenalty(toGroup+1) - samePerfPenalty(toGroup)
+
+	// コンフリクト: p に参加するユーザーだけが影響を受ける
+	fromAttend := pr.dateAttend[from]
+	toAttend := pr.dateAttend[to]
+	conflictDiff := 0
+	for _, u := range pr.memberList[p] {
+		before := s.conflictDates[u]
+		after := before
+		if s.memberLoad[from*userCount+u] == 2 && fromAttend.has(u) {
+			after--
+		}
+		if s.memberLoad[to*userCount+u] == 1 && toAttend.has(u) {
+			after++
+		}
+		if before == 0 && after > 0 {
+			conflictDiff++
+		} else if before > 0 && after == 0 {
+			conflictDiff--
+		}
+	}
+	delta += float64(conflictDiff) * 12.0
+
+	return delta
+}
+
+// applyMove はパフォーマンス p を日付 to に移動し、集計値を更新します
+// delta は moveDelta で計算済みの差分です
+func (s *annealState) applyMove(p, to int, delta float64) {
+	from := s.assign[p]
+	if from == to {
+		return
+	}
+
+	pr := s.pr
+	userCount := len(pr.userNames)
+
+	s.assign[p] = to
+	s.dateLoad[from]--
+	s.dateLoad[to]++
+	s.groupLoad[from*pr.groupCount+pr.group[p]]--
+	s.groupLoad[to*pr.groupCount+pr.group[p]]++
+	s.optionSum += pr.optionEnergy[p][to] - pr.optionEnergy[p][from]
+
+	fromAttend := pr.dateAttend[from]
+	toAttend := pr.dateAttend[to]
+	for _, u := range pr.memberList[p] {
+		before := s.conflictDates[u]
+
+		fromIdx := from*userCount + u
+		if s.memberLoad[fromIdx] == 2 && fromAttend.has(u) {
+			s.conflictDates[u]--
+		}
+		s.memberLoad[fromIdx]--
+
+		toIdx := to*userCount + u
+		s.memberLoad[toIdx]++
+		if s.memberLoad[toIdx] == 2 && toAttend.has(u) {
+			s.conflictDates[u]++
+		}
+
+		after := s.conflictDates[u]
+		if before == 0 && after > 0 {
+			s.conflictUsers++
+		} else if before > 0 && after == 0 {
+			s.conflictUsers--
+		}
+	}
+
+	s.energy += delta
+}
@@ -0,0 +1,249 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+// randomInstance はテスト用に、セッション展開済みのパフォーマンスと回答をランダムに生成します
+// 旧評価関数と比べられるよう、全員がすべての日付に回答している（未回答がない）ものとします
+func randomInstance(rng *rand.Rand, perfCount, sessionCount, dateCount, userCount int) ([]models.ScoredOption, map[string]*models.UserData) {
+	statuses := []models.AnswerStatus{models.StatusAvailable, models.StatusMaybe, models.StatusUnavailable}
+
+	users := make(map[string]*models.UserData, userCount)
+	for u := 0; u < userCount; u++ {
+		data := &models.UserData{
+			Name:         fmt.Sprintf("user%d", u),
+			Performances: make(map[uint]bool),
+			Availability: make(map[uint]models.AnswerStatus),
+		}
+		for p := 1; p <= perfCount; p++ {
+			if rng.Intn(4) == 0 {
+				for i := 1; i <= sessionCount; i++ {
+					data.Performances[uint(p*100+i)] = true
+				}
+			}
+		}
+		for d := 1; d <= dateCount; d++ {
+			data.Availability[uint(d)] = statuses[rng.Intn(len(statuses))]
+		}
+		users[data.Name] = data
+	}
+
+	var options []models.ScoredOption
+	for p := 1; p <= perfCount; p++ {
+		for i := 1; i <= sessionCount; i++ {
+			perfID := uint(p*100 + i)
+			for d := 1; d <= dateCount; d++ {
+				// 一部の組み合わせは候補にしない
+				if rng.Intn(10) == 0 {
+					continue
+				}
+				opt := models.ScoredOption{PerformanceID: perfID, DateID: uint(d), DateValue: fmt.Sprintf("2025-05-%02d 18:00-21:00", d)}
+				for _, data := range users {
+					if !data.Performances[perfID] {
+						continue
+					}
+					switch data.Availability[uint(d)] {
+					case models.StatusAvailable:
+						opt.AvailableCount++
+					case models.StatusMaybe:
+						opt.MaybeCount++
+					default:
+						opt.UnavailableCount++
+					}
+				}
+				options = append(options, opt)
+			}
+		}
+	}
+	return options, users
+}
+
+// initialAssignment は Optimize と同じく貪欲法の初期解をインデックスの割り当てに変換します
+func initialAssignment(pr *problem, options []models.ScoredOption) []int {
+	schedule := buildInitialSchedule(options, len(pr.perfIDs))
+	assign := make([]int, len(pr.perfIDs))
+	for p, perfID := range pr.perfIDs {
+		assign[p] = pr.dateIndex[schedule[perfID]]
+	}
+	return assign
+}
+
+// legacyEnergy はインデックス化する前の評価関数です
+// 割り当て全体からエネルギーを計算し直すため、差分評価の正しさの基準と速度の比較に使います
+func legacyEnergy(schedule Schedule, optionMap map[string]models.ScoredOption, users map[string]*models.UserData) float64 {
+	dateToPerfs := make(map[uint][]uint)
+	for perfID, dateID := range schedule {
+		dateToPerfs[dateID] = append(dateToPerfs[dateID], perfID)
+	}
+
+	totalConflicts := 0.0
+	conflictingUsers := make(map[string]bool)
+	totalAvailable := 0.0
+	totalUnavailable := 0.0
+
+	for perfID, dateID := range schedule {
+		if opt, exists := optionMap[legacyOptionKey(perfID, dateID)]; exists {
+			totalAvailable -= float64(opt.AvailableCount) + (float64(opt.MaybeCount) * 0.5)
+			totalUnavailable += float64(opt.UnavailableCount) * 20
+		}
+
+		if perfs := dateToPerfs[dateID]; len(perfs) > 1 {
+			for userName, userData := range users {
+				if !userData.Performances[perfID] {
+					continue
+				}
+				availability := userData.Availability[dateID]
+				if availability != models.StatusAvailable && availability != models.StatusMaybe {
+					continue
+				}
+				for _, otherPerfID := range perfs {
+					if otherPerfID != perfID && userData.Performances[otherPerfID] {
+						if !conflictingUsers[userName] {
+							totalConflicts += 1.0
+							conflictingUsers[userName] = true
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+
+	dateOverlapPenalty := 0.0
+	samePerformancePenalty := 0.0
+	for _, perfs := range dateToPerfs {
+		if len(perfs) > 1 {
+			dateOverlapPenalty += math.Pow(float64(len(perfs)-1), 1.5) * 2.0
+		}
+		origPerfCounts := make(map[uint]int)
+		for _, perfID := range perfs {
+			origPerfCounts[perfID/100]++
+		}
+		for _, count := range origPerfCounts {
+			if count > 1 {
+				samePerformancePenalty += float64(count * count * 50)
+			}
+		}
+	}
+
+	return (totalConflicts * 12.0) + totalAvailable + totalUnavailable + dateOverlapPenalty + samePerformancePenalty
+}
+
+func legacyOptionKey(perfID, dateID uint) string {
+	return fmt.Sprintf("%d-%d", perfID, dateID)
+}
+
+func legacyOptionMap(options []models.ScoredOption) map[string]models.ScoredOption {
+	optionMap := make(map[string]models.ScoredOption, len(options))
+	for _, opt := range options {
+		optionMap[legacyOptionKey(opt.PerformanceID, opt.DateID)] = opt
+	}
+	return optionMap
+}
+
+// legacySchedule はインデックスの割り当てを旧評価関数の形式に変換します
+func legacySchedule(pr *problem, assign []int) Schedule {
+	schedule := make(Schedule, len(assign))
+	for p, d := range assign {
+		schedule[pr.perfIDs[p]] = pr.dateIDs[d]
+	}
+	return schedule
+}
+
+func TestMoveDeltaMatchesFullEnergy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	options, users := randomInstance(rng, 8, 3, 15, 30)
+	optionMap := legacyOptionMap(options)
+	pr := newProblem(options, users, UnansweredMaybe)
+	s := newAnnealState(pr, initialAssignment(pr, options))
+
+	check := func(i int, moveType MoveType) {
+		t.Helper()
+		if full := newAnnealState(pr, s.assign).energy; math.Abs(s.energy-full) > 1e-6 {
+			t.Fatalf("move %d (%s): incremental energy %v, recomputed %v", i, moveType, s.energy, full)
+		}
+		if legacy := legacyEnergy(legacySchedule(pr, s.assign), optionMap, users); math.Abs(s.energy-legacy) > 1e-6 {
+			t.Fatalf("move %d (%s): incremental energy %v, legacy evaluator %v", i, moveType, s.energy, legacy)
+		}
+	}
+
+	check(0, "initial")
+	for i := 1; i <= 5000; i++ {
+		moveType := moveTypes[rng.Intn(len(moveTypes))]
+		steps := generateMove(s, moveType, rng)
+		if steps == nil {
+			continue
+		}
+
+		before := s.energy
+		_, undo := s.applySteps(steps)
+		check(i, moveType)
+
+		// 焼きなまし法と同じく、半分は元に戻して戻した後の集計値も確かめる
+		if rng.Intn(2) == 0 {
+			s.revertSteps(undo)
+			s.energy = before
+			check(i, moveType)
+		}
+	}
+}
+
+// benchmarkInstance は実際のイベントに近い大きさ（演目20・各3セッション・候補日40・メンバー60人）の問題です
+func benchmarkInstance() ([]models.ScoredOption, map[string]*models.UserData) {
+	return randomInstance(rand.New(rand.NewSource(42)), 20, 3, 40, 60)
+}
+
+// BenchmarkLegacyEvaluator は1手ごとに割り当て全体を評価し直す旧方式の評価です
+func BenchmarkLegacyEvaluator(b *testing.B) {
+	options, users := benchmarkInstance()
+	optionMap := legacyOptionMap(options)
+	pr := newProblem(options, users, UnansweredMaybe)
+	schedule := legacySchedule(pr, initialAssignment(pr, options))
+	rng := rand.New(rand.NewSource(1))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := rng.Intn(len(pr.perfIDs))
+		perfID := pr.perfIDs[p]
+		validDates := pr.validDates[p]
+		from := schedule[perfID]
+		schedule[perfID] = pr.dateIDs[validDates[rng.Intn(len(validDates))]]
+		legacyEnergy(schedule, optionMap, users)
+		schedule[perfID] = from
+	}
+}
+
+// BenchmarkMoveDelta は移動するパフォーマンスに関わる集計値だけを見る差分評価です
+func BenchmarkMoveDelta(b *testing.B) {
+	options, users := benchmarkInstance()
+	pr := newProblem(options, users, UnansweredMaybe)
+	s := newAnnealState(pr, initialAssignment(pr, options))
+	rng := rand.New(rand.NewSource(1))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := rng.Intn(len(pr.perfIDs))
+		validDates := pr.validDates[p]
+		s.moveDelta(p, validDates[rng.Intn(len(validDates))])
+	}
+}
+
+// BenchmarkAnneal は既定のパラメータでの最適化1回分です
+func BenchmarkAnneal(b *testing.B) {
+	options, users := benchmarkInstance()
+	pr := newProblem(options, users, UnansweredMaybe)
+	assign := initialAssignment(pr, options)
+	opts := DefaultOptions()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		simulatedAnnealing(context.Background(), pr, assign, opts, rand.New(rand.NewSource(int64(i))))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/raie03/schedule-app/backend/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -34,7 +35,10 @@ func Connect() (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN:                  dsn,
 		PreferSimpleProtocol: true,
-	}), &gorm.Config{})
+	}), &gorm.Config{
+		// 一意制約の違反を gorm.ErrDuplicatedKey として扱う
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -53,5 +57,100 @@ func Connect() (*gorm.DB, error) {
 	// 	return nil, err
 	// }
 
+	// 後から追加したテーブルのマイグレーション
+	err = db.AutoMigrate(
+		&models.OptimizationJob{},
+		&models.ScheduledSession{},
+		&models.EventTemplate{},
+		&models.TemplatePerformance{},
+		&models.User{},
+		&models.Organization{},
+		&models.Membership{},
+		&models.LoginToken{},
+		&models.RosterMember{},
+		&models.RosterAlias{},
+		&models.RosterPerformance{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 既存のテーブルに後から追加したカラム
+	columns := []struct {
+		model interface{}
+		field string
+	}{
+		{&models.Event{}, "PasswordHash"},
+		{&models.Event{}, "OrganizationID"},
+		{&models.Event{}, "UseRoster"},
+		{&models.Response{}, "UserID"},
+		{&models.Response{}, "MemberID"},
+		{&models.User{}, "Locale"},
+		{&models.User{}, "NotifyResponses"},
+		{&models.User{}, "NotifySchedule"},
+		{&models.User{}, "NotifyReminders"},
+		{&models.Event{}, "Deadline"},
+		{&models.Event{}, "AutoSchedule"},
+		{&models.Event{}, "AutoScheduleSessions"},
+		{&models.Event{}, "ReminderHours"},
+		{&models.Event{}, "RemindedAt"},
+		{&models.Event{}, "ClosedAt"},
+		{&models.Event{}, "AutoOptimizationID"},
+		{&models.Response{}, "NormalizedName"},
+	}
+	for _, col := range columns {
+		if db.Migrator().HasColumn(col.model, col.field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(col.model, col.field); err != nil {
+			return nil, err
+		}
+	}
+
+	// 回答の名前をイベントごとに一意にする
+	if err := backfillNormalizedNames(db); err != nil {
+		return nil, err
+	}
+	if !db.Migrator().HasIndex(&models.Response{}, "idx_responses_event_name") {
+		if err := db.Migrator().CreateIndex(&models.Response{}, "idx_responses_event_name"); err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
+
+// backfillNormalizedNames は正規化した名前のない回答に設定します
+// 同じイベントに正規化した名前が同じ回答が既にある場合は設定せず、重複の統合を待ちます
+func backfillNormalizedNames(db *gorm.DB) error {
+	var responses []models.Response
+	if err := db.Select("id", "event_id", "name").Where("normalized_name IS NULL").Order("id").Find(&responses).Error; err != nil {
+		return err
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+
+	var taken []models.Response
+	if err := db.Select("event_id", "normalized_name").Where("normalized_name IS NOT NULL").Find(&taken).Error; err != nil {
+		return err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, r := range taken {
+		used[r.EventID+"\n"+*r.NormalizedName] = true
+	}
+
+	for _, r := range responses {
+		name := models.NormalizeName(r.Name)
+		if used[r.EventID+"\n"+name] {
+			continue
+		}
+		used[r.EventID+"\n"+name] = true
+		if err := db.Model(&models.Response{}).Where("id = ?", r.ID).Update("normalized_name", name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
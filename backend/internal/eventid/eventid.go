@@ -0,0 +1,84 @@
+package eventid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultAlphabet はイベントIDに使う既定の文字です
+const DefaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// DefaultLength はイベントIDの既定の長さです
+const DefaultLength = 10
+
+// 設定できるIDの長さの範囲
+const (
+	minLength = 6
+	maxLength = 64
+)
+
+// urlSafe は URL にそのまま使える文字です（RFC 3986 の unreserved から、
+// セッショントークンの区切りに使う "." を除いたもの）
+const urlSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_~"
+
+// Generator は暗号論的乱数でイベントIDを生成します
+// ゼロ値は DefaultAlphabet と DefaultLength を使います
+type Generator struct {
+	alphabet string
+	length   int
+}
+
+// New は alphabet の文字から length 文字のIDを作る Generator を返します
+// 空の alphabet、0 の length は既定値を使います
+func New(alphabet string, length int) (Generator, error) {
+	if alphabet == "" {
+		alphabet = DefaultAlphabet
+	}
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	if length < minLength || length > maxLength {
+		return Generator{}, fmt.Errorf("event ID length must be between %d and %d", minLength, maxLength)
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if !strings.ContainsRune(urlSafe, r) {
+			return Generator{}, fmt.Errorf("event ID alphabet contains %q, which is not URL-safe", r)
+		}
+		if seen[r] {
+			return Generator{}, fmt.Errorf("event ID alphabet contains %q more than once", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		return Generator{}, fmt.Errorf("event ID alphabet needs at least 2 characters")
+	}
+
+	return Generator{alphabet: alphabet, length: length}, nil
+}
+
+// Generate は新しいIDを返します
+// 各文字は alphabet から一様に選ばれます（剰余による偏りはありません）
+func (g Generator) Generate() (string, error) {
+	alphabet, length := g.alphabet, g.length
+	if alphabet == "" {
+		alphabet = DefaultAlphabet
+	}
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	n := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[k.Int64()]
+	}
+	return string(b), nil
+}
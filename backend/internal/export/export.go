@@ -0,0 +1,98 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// utf8BOM は Excel が UTF-8 の CSV を正しく開くための BOM です
+const utf8BOM = "\xEF\xBB\xBF"
+
+// Table は1シート分の表です（先頭行が見出し）
+type Table struct {
+	Name string
+	Rows [][]string
+}
+
+// WriteCSV は表を BOM 付き UTF-8 の CSV として書き込みます
+func WriteCSV(w io.Writer, table Table) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if err := cw.WriteAll(table.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteXLSX は表をそれぞれ1シートとする XLSX ファイルを書き込みます
+func WriteXLSX(w io.Writer, tables ...Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for i, table := range tables {
+		sheet := table.Name
+		if i == 0 {
+			// 既定のシートを1枚目として使う
+			if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+
+		for r, row := range table.Rows {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				return err
+			}
+			values := make([]interface{}, len(row))
+			for c, v := range row {
+				values[c] = v
+			}
+			if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+				return err
+			}
+		}
+
+		// 見出し行と見出し列を固定する
+		if len(table.Rows) > 0 {
+			if err := f.SetPanes(sheet, &excelize.Panes{
+				Freeze:      true,
+				XSplit:      1,
+				YSplit:      1,
+				TopLeftCell: "B2",
+				ActivePane:  "bottomRight",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	f.SetActiveSheet(0)
+	_, err := f.WriteTo(w)
+	return err
+}
+
+// ReadCSV は CSV を読み込みます（先頭の BOM は取り除きます）
+// 列数が行ごとに異なっていても読み込みます
+func ReadCSV(r io.Reader) ([][]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], utf8BOM)
+	}
+	return rows, nil
+}
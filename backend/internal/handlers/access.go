@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/access"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// requestTokens はリクエストのセッショントークンを返します
+// Authorization: Bearer と、EventSource やカレンダーの購読 URL のための token クエリの両方を見るため、
+// アカウントのトークンとイベントの閲覧用トークンを同時に渡せます
+func requestTokens(c *gin.Context) []string {
+	var tokens []string
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		tokens = append(tokens, strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+	}
+	if token := c.Query("token"); token != "" {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// RequireViewer is middleware that requires a session token for password-protected events
+// イベントを所有する団体のメンバーはパスワードなしで閲覧できます
+// 存在しないイベントは 404、読み込めない場合は 500 で止め、パスワードのないイベントだけをそのまま通します
+func (h *Handler) RequireViewer(c *gin.Context) {
+	h.requireViewer(c, false)
+}
+
+// RequireCalendarViewer is RequireViewer for calendar subscription (.ics) routes
+// 閲覧用のトークンに加えて、CreateCalendarToken で発行したカレンダー購読用のトークンも受け付けます
+func (h *Handler) RequireCalendarViewer(c *gin.Context) {
+	h.requireViewer(c, true)
+}
+
+// requireViewer は RequireViewer と RequireCalendarViewer の共通部分です
+func (h *Handler) requireViewer(c *gin.Context, calendar bool) {
+	id := c.Param("id")
+	if id == "" {
+		c.Next()
+		return
+	}
+
+	var event models.Event
+	err := h.db.Select("id", "password_hash", "organization_id").Where("id = ?", id).First(&event).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("failed to load event for access check", "event_id", id, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	case h.canView(c, event), calendar && h.hasCalendarToken(c, event):
+		c.Next()
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Password required"})
+}
+
+// RequireOrganizer is middleware that allows only organizers to change an event
+// 団体のイベントはその団体のメンバーだけが変更でき、匿名のイベントは閲覧できる人なら誰でも変更できます
+// RequireViewer の後に使います
+func (h *Handler) RequireOrganizer(c *gin.Context) {
+	var event models.Event
+	err := h.db.Select("id", "organization_id").Where("id = ?", c.Param("id")).First(&event).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("failed to load event for organizer check", "event_id", c.Param("id"), "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event"})
+		return
+	}
+	if !h.requireOrganizer(c, event) {
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// canView はリクエストのトークンか団体への所属でイベントを閲覧できるかを返します
+// event は password_hash と organization_id を読み込んでいる必要があります
+func (h *Handler) canView(c *gin.Context, event models.Event) bool {
+	if !event.Protected {
+		return true
+	}
+	for _, token := range requestTokens(c) {
+		if h.cfg.Sessions.Verify(token, event.ID, event.PasswordHash, time.Now()) == nil {
+			return true
+		}
+	}
+	userID, ok := h.currentUserID(c)
+	return ok && event.OrganizationID != nil && h.isMember(*event.OrganizationID, userID)
+}
+
+// hasCalendarToken はリクエストにイベントのカレンダー購読用トークンがあるかを返します
+func (h *Handler) hasCalendarToken(c *gin.Context, event models.Event) bool {
+	for _, token := range requestTokens(c) {
+		if h.cfg.Calendars.Verify(token, event.ID, event.PasswordHash, time.Now()) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSession exchanges an event's viewer password for a short-lived session token
+func (h *Handler) CreateSession(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Select("id", "password_hash").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var req models.CreateSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// パスワードのないイベントでもトークンを発行し、クライアントの処理を共通にする
+	if event.Protected && !access.CheckPassword(event.PasswordHash, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	token, expires := h.cfg.Sessions.Issue(event.ID, event.PasswordHash, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expires,
+	})
+}
+
+// CreateCalendarToken issues a long-lived token for the event's calendar subscription (.ics) URLs
+// 閲覧用トークンは有効期間が短く、カレンダーアプリの購読が途中で止まるため、
+// .ics の URL にだけ使える別のトークンを発行します。パスワードを変えると無効になります
+func (h *Handler) CreateCalendarToken(c *gin.Context) {
+	var event models.Event
+	if err := h.db.Select("id", "password_hash").Where("id = ?", c.Param("id")).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	token, expires := h.cfg.Calendars.Issue(event.ID, event.PasswordHash, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expires,
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/access"
+	"github.com/raie03/schedule-app/backend/internal/mail"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// loginTokenTTL はマジックリンクの有効期間です
+const loginTokenTTL = 15 * time.Minute
+
+// normalizeEmail はメールアドレスを比較用に正規化します
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// currentUserID はリクエストのアカウントのセッショントークンを検証し、ユーザーIDを返します
+// ログインしていない場合は false を返します（エラーレスポンスは書き込みません）
+func (h *Handler) currentUserID(c *gin.Context) (uint, bool) {
+	for _, token := range requestTokens(c) {
+		if !access.IsUserToken(token) {
+			continue
+		}
+		if id, err := h.cfg.Accounts.VerifyUser(token, time.Now()); err == nil {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// requireUser はログインしているユーザーを取得します
+// ログインしていない場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) requireUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	id, ok := h.currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return user, false
+	}
+	if err := h.db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return user, false
+	}
+	return user, true
+}
+
+// RequestLogin sends a magic link for signing in to the given email address
+// アドレスが登録済みかどうかは応答から分からないようにし、未登録の場合はリンクを開いたときに作成します
+func (h *Handler) RequestLogin(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := normalizeEmail(req.Email)
+
+	token, hash := access.NewLoginToken()
+	login := models.LoginToken{
+		Email:     email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(loginTokenTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.Create(&login).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login link"})
+		return
+	}
+
+	link := strings.TrimRight(h.cfg.AppURL, "/") + "/login/verify?token=" + url.QueryEscape(token)
+	err := h.cfg.Mail.Send(c.Request.Context(), mail.Message{
+		To:      email,
+		Subject: "ログインリンク",
+		Body: "以下のリンクを開くとログインできます（" + loginTokenTTL.String() + " 有効）。\n\n" +
+			link + "\n\n心当たりがない場合はこのメールを破棄してください。\n",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Login link sent"})
+}
+
+// VerifyLogin exchanges a magic link token for an account session token
+func (h *Handler) VerifyLogin(c *gin.Context) {
+	var req models.VerifyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	errInvalidLink := errors.New("invalid link")
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var login models.LoginToken
+		if err := tx.Where("token_hash = ?", access.HashLoginToken(req.Token)).First(&login).Error; err != nil {
+			return errInvalidLink
+		}
+		if login.UsedAt != nil || !time.Now().Before(login.ExpiresAt) {
+			return errInvalidLink
+		}
+
+		// 同じリンクが同時に使われても1回だけ成功させる
+		now := time.Now()
+		result := tx.Model(&models.LoginToken{}).
+			Where("id = ? AND used_at IS NULL", login.ID).
+			Update("used_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidLink
+		}
+
+		return tx.Where(models.User{Email: login.Email}).
+			Attrs(models.User{CreatedAt: now}).
+			FirstOrCreate(&user).Error
+	})
+	if errors.Is(err, errInvalidLink) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	token, expires := h.cfg.Accounts.IssueUser(user.ID, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expires,
+		"user":       user,
+	})
+}
+
+// GetMe retrieves the logged-in user with the organizations they belong to
+func (h *Handler) GetMe(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	type orgSummary struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	organizations := make([]orgSummary, 0)
+	err := h.db.Model(&models.Membership{}).
+		Select("organizations.id, organizations.name, memberships.role").
+		Joins("JOIN organizations ON organizations.id = memberships.organization_id").
+		Where("memberships.user_id = ?", user.ID).
+		Order("organizations.name").
+		Scan(&organizations).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":          user,
+		"organizations": organizations,
+	})
+}
+
+// UpdateMe updates the logged-in user's display name
+func (h *Handler) UpdateMe(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	if err := h.db.Model(&user).Update("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/ical"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/recurrence"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+	"gorm.io/gorm"
+)
+
+// maxImportDays は .ics から候補日を取り込む期間の上限（日数）です
+const maxImportDays = 366
+
+// slotFilter は .ics の空き時間から候補日を作るときの条件です
+type slotFilter struct {
+	From     time.Time     // 期間の初日
+	To       time.Time     // 期間の最終日
+	DayStart string        // 1日のうち候補にする時間帯の開始 "09:00"
+	DayEnd   string        // 同じく終了 "22:00"
+	Length   time.Duration // 0 以外なら空き時間をこの長さの枠に分割する
+	Min      time.Duration // これより短い空き時間は候補にしない
+}
+
+// parseSlotFilter はクエリから取り込み条件を読み取ります
+// from・to（"2025-04-01"）は必須、start・end は既定で 09:00-22:00、
+// length・min は分単位で min の既定は 60 分です
+func parseSlotFilter(c *gin.Context, loc *time.Location) (slotFilter, error) {
+	filter := slotFilter{
+		DayStart: c.DefaultQuery("start", "09:00"),
+		DayEnd:   c.DefaultQuery("end", "22:00"),
+	}
+
+	var err error
+	if filter.From, err = time.ParseInLocation("2006-01-02", c.Query("from"), loc); err != nil {
+		return filter, fmt.Errorf("from must be a date like 2025-04-01")
+	}
+	if filter.To, err = time.ParseInLocation("2006-01-02", c.Query("to"), loc); err != nil {
+		return filter, fmt.Errorf("to must be a date like 2025-04-30")
+	}
+	if filter.To.Before(filter.From) {
+		return filter, fmt.Errorf("to must not be before from")
+	}
+	if filter.To.Sub(filter.From) >= maxImportDays*24*time.Hour {
+		return filter, fmt.Errorf("the window must be at most %d days", maxImportDays)
+	}
+
+	// 時間帯は Date.Value と同じ書式で検証する
+	if _, err := timeslot.Parse(fmt.Sprintf("%s %s-%s", c.Query("from"), filter.DayStart, filter.DayEnd), loc); err != nil {
+		return filter, fmt.Errorf("start and end must be times like 19:00")
+	}
+
+	length, err := strconv.Atoi(c.DefaultQuery("length", "0"))
+	if err != nil || length < 0 {
+		return filter, fmt.Errorf("length must be minutes")
+	}
+	min, err := strconv.Atoi(c.DefaultQuery("min", "60"))
+	if err != nil || min < 0 {
+		return filter, fmt.Errorf("min must be minutes")
+	}
+	filter.Length = time.Duration(length) * time.Minute
+	filter.Min = time.Duration(min) * time.Minute
+	return filter, nil
+}
+
+// freeSlots は期間内の各日の時間帯から予定のある時間を除き、候補の時間帯を返します
+// FREEBUSY に空き時間（FBTYPE=FREE）がある場合は、その範囲だけを候補にします
+func freeSlots(fb ical.FreeBusy, filter slotFilter, loc *time.Location) []timeslot.Slot {
+	// 仮予約も埋まっている時間として扱う
+	busy := toSlots(fb.Busy, fb.Tentative)
+	free := toSlots(fb.Free)
+
+	var result []timeslot.Slot
+	for day := filter.From; !day.After(filter.To); day = day.AddDate(0, 0, 1) {
+		window, err := timeslot.Parse(fmt.Sprintf("%s %s-%s", day.Format("2006-01-02"), filter.DayStart, filter.DayEnd), loc)
+		if err != nil {
+			continue
+		}
+
+		candidates := []timeslot.Slot{window}
+		if len(free) > 0 {
+			candidates = timeslot.Intersect(window, free)
+		}
+
+		for _, slot := range timeslot.Subtract(candidates, busy) {
+			if slot.End.Sub(slot.Start) < filter.Min {
+				continue
+			}
+			result = append(result, timeslot.Split(slot, filter.Length)...)
+		}
+	}
+	return result
+}
+
+// toSlots は iCalendar の時間帯を timeslot.Slot に変換します
+func toSlots(periods ...[]ical.Period) []timeslot.Slot {
+	var slots []timeslot.Slot
+	for _, ps := range periods {
+		for _, p := range ps {
+			slots = append(slots, timeslot.Slot(p))
+		}
+	}
+	return slots
+}
+
+// ImportDatesFromICS adds candidate dates from the free time of an iCalendar file
+// dry_run=true の場合は候補を返すだけで保存しません
+func (h *Handler) ImportDatesFromICS(c *gin.Context) {
+	id := c.Param("id")
+	dryRun := c.Query("dry_run") == "true"
+	loc := h.location()
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	filter, err := parseSlotFilter(c, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := openUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+	defer f.Close()
+
+	// 繰り返しの予定は取り込む期間の分だけ展開する
+	fb, err := ical.ParseFreeBusy(f, loc, ical.Period{Start: filter.From, End: filter.To.AddDate(0, 0, 1)})
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid iCalendar: " + err.Error()})
+		return
+	}
+
+	// 既に候補にある日時は追加しない
+	existing := make(map[string]bool, len(event.Dates))
+	for _, date := range event.Dates {
+		existing[date.Value] = true
+	}
+
+	dates := make([]models.Date, 0)
+	skipped := make([]string, 0)
+	for _, slot := range freeSlots(fb, filter, loc) {
+		value := timeslot.Format(slot, loc)
+		if existing[value] {
+			skipped = append(skipped, value)
+			continue
+		}
+		existing[value] = true
+		dates = append(dates, models.Date{EventID: id, Value: value})
+	}
+
+	if !checkLimit(c, "dates", len(event.Dates)+len(dates), h.cfg.MaxDates) {
+		return
+	}
+	if dryRun || len(dates) == 0 {
+		c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "dates": dates, "skipped": skipped})
+		return
+	}
+
+	if err := h.createDates(id, dates); err != nil {
+		if errors.Is(err, errTooManyDates) {
+			checkLimit(c, "dates", h.cfg.MaxDates+1, h.cfg.MaxDates)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dates"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"dry_run": false, "dates": dates, "skipped": skipped})
+}
+
+// expandDateRule は繰り返しルールを Date.Value の一覧に展開します
+func expandDateRule(rule models.DateRuleRequest, loc *time.Location) ([]string, error) {
+	rrule, err := recurrence.Parse(rule.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	from, err := time.ParseInLocation("2006-01-02", rule.From, loc)
+	if err != nil {
+		return nil, fmt.Errorf("from must be a date like 2025-04-01")
+	}
+	if _, err := timeslot.Parse(fmt.Sprintf("%s %s-%s", rule.From, rule.Start, rule.End), loc); err != nil {
+		return nil, fmt.Errorf("start and end must be times like 19:00")
+	}
+
+	exclude := make(map[string]bool, len(rule.Exclude))
+	for _, value := range rule.Exclude {
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(value), loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded date %q", value)
+		}
+		exclude[day.Format("2006-01-02")] = true
+	}
+
+	days, err := rrule.Expand(from, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(days))
+	for _, day := range days {
+		slot, err := timeslot.Parse(fmt.Sprintf("%s %s-%s", day.Format("2006-01-02"), rule.Start, rule.End), loc)
+		if err != nil {
+			return nil, err
+		}
+		// "9:00" などの表記を Date.Value の書式にそろえる
+		values = append(values, timeslot.Format(slot, loc))
+	}
+	return values, nil
+}
+
+// expandDates は指定された日付と繰り返しルールから追加する Date.Value を作ります
+// 既存の日付やリクエスト内で重複する値は skipped に入れて追加しません
+func expandDates(values []string, rules []models.DateRuleRequest, existing []models.Date, loc *time.Location) ([]string, []string, error) {
+	for i, rule := range rules {
+		expanded, err := expandDateRule(rule, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("date_rules[%d]: %w", i, err)
+		}
+		values = append(values, expanded...)
+	}
+
+	seen := make(map[string]bool, len(existing)+len(values))
+	for _, date := range existing {
+		seen[date.Value] = true
+	}
+
+	dates := make([]string, 0, len(values))
+	skipped := make([]string, 0)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil, fmt.Errorf("dates must not be empty")
+		}
+		if seen[value] {
+			skipped = append(skipped, value)
+			continue
+		}
+		seen[value] = true
+		dates = append(dates, value)
+	}
+	return dates, skipped, nil
+}
+
+// createDates は作成済みのイベントに日付を追加します
+// 既存の回答には新しい日付を未回答（unanswered）として登録し、参加不可と区別します
+// 件数の上限はイベントをロックしてから数え直し、超える場合は errTooManyDates を返します
+func (h *Handler) createDates(eventID string, dates []models.Date) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		count, err := lockDateCount(tx, eventID)
+		if err != nil {
+			return err
+		}
+		if h.cfg.MaxDates > 0 && count+len(dates) > h.cfg.MaxDates {
+			return errTooManyDates
+		}
+		if err := tx.Create(&dates).Error; err != nil {
+			return err
+		}
+
+		var responseIDs []uint
+		if err := tx.Model(&models.Response{}).Where("event_id = ?", eventID).Pluck("id", &responseIDs).Error; err != nil {
+			return err
+		}
+		if len(responseIDs) == 0 {
+			return nil
+		}
+
+		answers := make([]models.ResponseAnswer, 0, len(responseIDs)*len(dates))
+		for _, responseID := range responseIDs {
+			for _, date := range dates {
+				answers = append(answers, models.ResponseAnswer{
+					ResponseID: responseID,
+					DateID:     date.ID,
+					Status:     models.StatusUnanswered,
+				})
+			}
+		}
+		return tx.Create(&answers).Error
+	})
+}
+
+// AddDates adds candidate dates to an event from values and recurrence rules
+func (h *Handler) AddDates(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var req models.AddDatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	values, skipped, err := expandDates(req.Dates, req.DateRules, event.Dates, h.location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dates := make([]models.Date, 0, len(values))
+	for _, value := range values {
+		dates = append(dates, models.Date{EventID: id, Value: value})
+	}
+	if !checkLimit(c, "dates", len(event.Dates)+len(dates), h.cfg.MaxDates) {
+		return
+	}
+	if len(dates) > 0 {
+		if err := h.createDates(id, dates); err != nil {
+			if errors.Is(err, errTooManyDates) {
+				checkLimit(c, "dates", h.cfg.MaxDates+1, h.cfg.MaxDates)
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dates"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"dates": dates, "skipped": skipped})
+}
+
+// DeleteDate removes a candidate date with its answers
+// 確定スケジュールで使われている日付は削除できません
+func (h *Handler) DeleteDate(c *gin.Context) {
+	id := c.Param("id")
+
+	var date models.Date
+	if err := h.db.Where("id = ? AND event_id = ?", c.Param("dateId"), id).First(&date).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Date not found"})
+		return
+	}
+
+	var scheduled int64
+	if err := h.db.Model(&models.ScheduledSession{}).Where("date_id = ?", date.ID).Count(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check schedule"})
+		return
+	}
+	if scheduled > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Date is used in the confirmed schedule"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("date_id = ?", date.ID).Delete(&models.ResponseAnswer{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&date).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete date"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Date deleted successfully"})
+}
+
+// GetUnansweredDates lists the members who still need to answer each date
+// 回答がない日付と未回答（unanswered）の日付を対象にします
+func (h *Handler) GetUnansweredDates(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var responses []models.Response
+	if err := h.db.Preload("Answers").Where("event_id = ?", id).Order("id").Find(&responses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+
+	type pendingDate struct {
+		DateID  uint     `json:"date_id"`
+		Value   string   `json:"value"`
+		Members []string `json:"members"`
+	}
+	type pendingMember struct {
+		ResponseID uint   `json:"response_id"`
+		Name       string `json:"name"`
+		DateIDs    []uint `json:"date_ids"`
+	}
+
+	dates := make([]pendingDate, 0, len(event.Dates))
+	dateIndex := make(map[uint]int, len(event.Dates))
+	for i, date := range event.Dates {
+		dateIndex[date.ID] = i
+		dates = append(dates, pendingDate{DateID: date.ID, Value: date.Value, Members: []string{}})
+	}
+
+	members := make([]pendingMember, 0)
+	for _, response := range responses {
+		answered := make(map[uint]bool, len(response.Answers))
+		for _, answer := range response.Answers {
+			if answer.Status != models.StatusUnanswered {
+				answered[answer.DateID] = true
+			}
+		}
+
+		member := pendingMember{ResponseID: response.ID, Name: response.Name}
+		for _, date := range event.Dates {
+			if answered[date.ID] {
+				continue
+			}
+			member.DateIDs = append(member.DateIDs, date.ID)
+			pd := &dates[dateIndex[date.ID]]
+			pd.Members = append(pd.Members, response.Name)
+		}
+		if len(member.DateIDs) > 0 {
+			members = append(members, member)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dates": dates, "members": members})
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/jobs"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/notify"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+)
+
+// defaultDeadlineInterval は締め切りを確認する既定の間隔です
+const defaultDeadlineInterval = time.Minute
+
+// applyDeadline はイベントに締め切りの設定を反映します
+// 締め切りを変更した場合は催促・締め切り後の処理をやり直せるよう記録を消します
+func applyDeadline(event *models.Event, req models.DeadlineRequest) {
+	changed := (event.Deadline == nil) != (req.Deadline == nil) ||
+		(event.Deadline != nil && !event.Deadline.Equal(*req.Deadline))
+	if changed {
+		event.RemindedAt = nil
+		event.ClosedAt = nil
+		event.AutoOptimizationID = ""
+	}
+	event.Deadline = req.Deadline
+	event.AutoSchedule = req.AutoSchedule
+	event.AutoScheduleSessions = req.AutoScheduleSessions
+	event.ReminderHours = req.ReminderHours
+}
+
+// checkOpen はイベントが回答を受け付けているかを確認します
+// 締め切りを過ぎている場合は 403 を書き込み false を返します
+func checkOpen(c *gin.Context, event models.Event) bool {
+	if event.Closed(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":    "The response deadline for this event has passed",
+			"deadline": event.Deadline,
+		})
+		return false
+	}
+	return true
+}
+
+// UpdateDeadline changes the response deadline, automatic scheduling and reminder settings of an event
+// 過去の日時を指定するとすぐに締め切ります。deadline を省略すると締め切りをなくします
+func (h *Handler) UpdateDeadline(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeadlineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkSessions(c, req.AutoScheduleSessions) {
+		return
+	}
+
+	applyDeadline(&event, req)
+	event.UpdatedAt = time.Now()
+	err := h.db.Model(&event).Select(
+		"deadline", "auto_schedule", "auto_schedule_sessions", "reminder_hours",
+		"reminded_at", "closed_at", "auto_optimization_id", "updated_at",
+	).Updates(&event).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update deadline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// SendReminders emails the members who have not answered yet
+// 送信した後は締め切り前の自動の催促は行いません
+func (h *Handler) SendReminders(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+	if event.Deadline == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event has no deadline"})
+		return
+	}
+	if event.Closed(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The response deadline for this event has passed"})
+		return
+	}
+
+	sent, err := h.sendReminders(c.Request.Context(), event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reminders"})
+		return
+	}
+	h.db.Model(&models.Event{}).Where("id = ?", event.ID).Update("reminded_at", time.Now())
+
+	c.JSON(http.StatusOK, gin.H{"sent": sent})
+}
+
+// unansweredUsers は団体のイベントにまだ回答していないメンバーのうち、催促を希望するユーザーを返します
+// 名簿と結び付いたイベントでは名簿のメンバーのアカウント、そうでなければ団体のメンバーが対象です
+func (h *Handler) unansweredUsers(event models.Event) ([]models.User, error) {
+	var users []models.User
+	if event.OrganizationID == nil {
+		return users, nil
+	}
+
+	answeredUsers := h.db.Model(&models.Response{}).Select("user_id").
+		Where("event_id = ? AND user_id IS NOT NULL", event.ID)
+	query := h.db.Where("notify_reminders = ? AND id NOT IN (?)", true, answeredUsers)
+	if event.UseRoster {
+		answeredMembers := h.db.Model(&models.Response{}).Select("member_id").
+			Where("event_id = ? AND member_id IS NOT NULL", event.ID)
+		members := h.db.Model(&models.RosterMember{}).Select("user_id").
+			Where("organization_id = ? AND user_id IS NOT NULL AND id NOT IN (?)", *event.OrganizationID, answeredMembers)
+		query = query.Where("id IN (?)", members)
+	} else {
+		members := h.db.Model(&models.Membership{}).Select("user_id").
+			Where("organization_id = ?", *event.OrganizationID)
+		query = query.Where("id IN (?)", members)
+	}
+
+	err := query.Order("id").Find(&users).Error
+	return users, err
+}
+
+// sendReminders は未回答のメンバーに締め切りが近いことを知らせ、送信した件数を返します
+func (h *Handler) sendReminders(ctx context.Context, event models.Event) (int, error) {
+	users, err := h.unansweredUsers(event)
+	if err != nil {
+		return 0, err
+	}
+
+	data := notify.DeadlineReminderData{
+		EventTitle: event.Title,
+		EventURL:   h.eventURL(event.ID),
+		Deadline:   event.Deadline.In(h.location()).Format("2006-01-02 15:04 MST"),
+	}
+	for _, user := range users {
+		h.notifier.Notify(ctx, recipient(user), notify.DeadlineReminder, data)
+	}
+	return len(users), nil
+}
+
+// closeEvent は締め切りを過ぎたイベントを通知し、設定されていれば最適化のジョブを登録します
+// 最適化の結果はジョブとして保存し、確定は主催者に任せます
+func (h *Handler) closeEvent(ctx context.Context, event models.Event) {
+	logger := logging.FromContext(ctx).With("event_id", event.ID)
+	logger.Info("event closed", "deadline", event.Deadline, "auto_schedule", event.AutoSchedule)
+	h.publish(ctx, event.ID, realtime.Message{Type: realtime.EventClosed, Data: gin.H{"deadline": event.Deadline}})
+	if !event.AutoSchedule {
+		return
+	}
+
+	input, err := readOptimizationInput(h.db, event.ID, event.AutoScheduleSessions)
+	if err != nil {
+		logger.Error("Failed to load optimization input", "error", err)
+		return
+	}
+	job, err := h.jobs.Submit(event.ID, event.AutoScheduleSessions, optimizationJob(logger, input, algorithm.DefaultOptions()))
+	if errors.Is(err, jobs.ErrQueueFull) {
+		logger.Warn("Optimization queue is full; skipped automatic scheduling")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to start automatic scheduling", "error", err)
+		return
+	}
+	h.db.Model(&models.Event{}).Where("id = ?", event.ID).Update("auto_optimization_id", job.ID)
+}
+
+// processDeadlines は催促の時刻になったイベントに催促を送り、締め切りを過ぎたイベントを閉じます
+// 複数のインスタンスで実行しても、記録を先に更新できたインスタンスだけが処理します
+func (h *Handler) processDeadlines(ctx context.Context, now time.Time) {
+	logger := logging.FromContext(ctx)
+
+	var upcoming []models.Event
+	err := h.db.Where("reminder_hours > 0 AND reminded_at IS NULL AND deadline > ?", now).Find(&upcoming).Error
+	if err != nil {
+		logger.Error("Failed to load upcoming deadlines", "error", err)
+	}
+	for _, event := range upcoming {
+		if now.Add(time.Duration(event.ReminderHours) * time.Hour).Before(*event.Deadline) {
+			continue
+		}
+		claimed := h.db.Model(&models.Event{}).Where("id = ? AND reminded_at IS NULL", event.ID).Update("reminded_at", now)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+		sent, err := h.sendReminders(ctx, event)
+		if err != nil {
+			logger.Error("Failed to send reminders", "event_id", event.ID, "error", err)
+			continue
+		}
+		logger.Info("reminders sent", "event_id", event.ID, "recipients", sent)
+	}
+
+	var due []models.Event
+	if err := h.db.Where("closed_at IS NULL AND deadline <= ?", now).Find(&due).Error; err != nil {
+		logger.Error("Failed to load passed deadlines", "error", err)
+		return
+	}
+	for _, event := range due {
+		claimed := h.db.Model(&models.Event{}).Where("id = ? AND closed_at IS NULL", event.ID).Update("closed_at", now)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+		h.closeEvent(ctx, event)
+	}
+}
+
+// StartDeadlineScheduler starts a goroutine that sends reminders and closes events at their deadlines
+func (h *Handler) StartDeadlineScheduler(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDeadlineInterval
+	}
+	ctx := logging.WithLogger(context.Background(), slog.Default().With("component", "deadlines"))
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.processDeadlines(ctx, now)
+		}
+	}()
+}
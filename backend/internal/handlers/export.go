@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/export"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+)
+
+// エクスポートできる表の種類
+const (
+	exportAvailability  = "availability"
+	exportParticipation = "participation"
+	exportSchedule      = "schedule"
+	exportAll           = "all" // XLSX のみ: 全ての表を1ファイルに
+)
+
+// statusLabels は回答状態の表示記号です
+var statusLabels = map[models.AnswerStatus]string{
+	models.StatusAvailable:   "○",
+	models.StatusMaybe:       "△",
+	models.StatusUnavailable: "×",
+	models.StatusUnanswered:  "未回答",
+}
+
+// ExportTable exports responses or the schedule as CSV or XLSX
+// :table は availability / participation / schedule / all（XLSX のみ）、
+// format クエリは csv（既定）または xlsx です
+func (h *Handler) ExportTable(c *gin.Context) {
+	id := c.Param("id")
+	table := c.Param("table")
+	format := c.DefaultQuery("format", "csv")
+
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+	switch table {
+	case exportAvailability, exportParticipation, exportSchedule:
+	case exportAll:
+		if format != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "all is only available as xlsx"})
+			return
+		}
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown export"})
+		return
+	}
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Preload("Performances").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var responses []models.Response
+	if err := h.db.Preload("Answers").Preload("Performances").Where("event_id = ?", id).Order("id").Find(&responses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+
+	var tables []export.Table
+	if table == exportAvailability || table == exportAll {
+		tables = append(tables, availabilityTable(event, responses))
+	}
+	if table == exportParticipation || table == exportAll {
+		tables = append(tables, participationTable(event, responses))
+	}
+	if table == exportSchedule || table == exportAll {
+		scheduleTable, ok := h.scheduleTable(c, event, responses)
+		if !ok {
+			return
+		}
+		tables = append(tables, scheduleTable)
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+	var err error
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = export.WriteXLSX(&buf, tables...)
+	} else {
+		contentType = "text/csv; charset=utf-8"
+		err = export.WriteCSV(&buf, tables[0])
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, event.ID, table, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// availabilityTable はメンバー × 日付の参加可否の表を作成します
+func availabilityTable(event models.Event, responses []models.Response) export.Table {
+	header := []string{"名前"}
+	for _, date := range event.Dates {
+		header = append(header, date.Value)
+	}
+
+	rows := [][]string{header}
+	for _, response := range responses {
+		statuses := make(map[uint]models.AnswerStatus, len(response.Answers))
+		for _, answer := range response.Answers {
+			statuses[answer.DateID] = answer.Status
+		}
+
+		row := []string{response.Name}
+		for _, date := range event.Dates {
+			status, ok := statuses[date.ID]
+			label, known := statusLabels[status]
+			switch {
+			case !ok:
+				label = ""
+			case !known:
+				label = string(status)
+			}
+			row = append(row, label)
+		}
+		rows = append(rows, row)
+	}
+
+	return export.Table{Name: "参加可否", Rows: rows}
+}
+
+// participationTable はメンバー × 演目の参加表を作成します
+func participationTable(event models.Event, responses []models.Response) export.Table {
+	header := []string{"名前"}
+	for _, perf := range event.Performances {
+		header = append(header, perf.Title)
+	}
+
+	rows := [][]string{header}
+	for _, response := range responses {
+		joined := make(map[uint]bool, len(response.Performances))
+		for _, up := range response.Performances {
+			joined[up.PerformanceID] = true
+		}
+
+		row := []string{response.Name}
+		for _, perf := range event.Performances {
+			if joined[perf.ID] {
+				row = append(row, "○")
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return export.Table{Name: "参加演目", Rows: rows}
+}
+
+// scheduleTable は確定スケジュール（source=confirmed、既定）または
+// 最適化による提案スケジュール（source=suggested）の表を作成します
+func (h *Handler) scheduleTable(c *gin.Context, event models.Event, responses []models.Response) (export.Table, bool) {
+	header := []string{"日付", "演目", "練習回", "参加可能", "未定", "参加不可", "未回答", "コンフリクト"}
+	rows := [][]string{header}
+
+	switch c.DefaultQuery("source", "confirmed") {
+	case "confirmed":
+		sessions, err := h.loadConfirmedSchedule(event.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedule"})
+			return export.Table{}, false
+		}
+
+		input := newOptimizationInput(event, responses, 0)
+		options := make(map[[2]uint]models.ScoredOption, len(input.Options))
+		for _, opt := range input.Options {
+			options[[2]uint{opt.PerformanceID, opt.DateID}] = opt
+		}
+
+		// 同じ日付に確定しているパフォーマンス
+		datePerfs := make(map[uint][]uint)
+		for _, s := range sessions {
+			datePerfs[s.DateID] = append(datePerfs[s.DateID], s.PerformanceID)
+		}
+
+		for _, s := range sessions {
+			opt := options[[2]uint{s.PerformanceID, s.DateID}]
+			conflicts := confirmedConflicts(input.Users, s.PerformanceID, s.DateID, datePerfs[s.DateID])
+			rows = append(rows, []string{
+				s.Date.Value,
+				s.Performance.Title,
+				strconv.Itoa(s.Session),
+				strconv.Itoa(opt.AvailableCount),
+				strconv.Itoa(opt.MaybeCount),
+				strconv.Itoa(opt.UnavailableCount),
+				strconv.Itoa(opt.UnansweredCount),
+				strings.Join(conflicts, "、"),
+			})
+		}
+
+	case "suggested":
+		sessionCount, err := strconv.Atoi(c.DefaultQuery("sessions", "0"))
+		if err != nil || sessionCount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sessions"})
+			return export.Table{}, false
+		}
+		if !h.checkSessions(c, sessionCount) {
+			return export.Table{}, false
+		}
+
+		opts, err := optimizerOptions(c.Query("moves"), c.Query("unanswered"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return export.Table{}, false
+		}
+
+		ctx, cancel, err := h.optimizeContext(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return export.Table{}, false
+		}
+		defer cancel()
+
+		input := newOptimizationInput(event, responses, sessionCount)
+		result := algorithm.Optimize(ctx, input.Options, input.PerfCount, input.Users, opts)
+
+		// 日時の順に並べる（解釈できない日付は最後）
+		schedule := result.Schedule
+		starts := make(map[uint]time.Time, len(schedule))
+		for _, opt := range schedule {
+			if slot, err := timeslot.Parse(opt.DateValue, h.location()); err == nil {
+				starts[opt.DateID] = slot.Start
+			}
+		}
+		sort.SliceStable(schedule, func(i, j int) bool {
+			a, aOK := starts[schedule[i].DateID]
+			b, bOK := starts[schedule[j].DateID]
+			switch {
+			case aOK != bOK:
+				return aOK
+			case !a.Equal(b):
+				return a.Before(b)
+			case schedule[i].DateID != schedule[j].DateID:
+				return schedule[i].DateID < schedule[j].DateID
+			}
+			return schedule[i].PerformanceID < schedule[j].PerformanceID
+		})
+
+		for _, opt := range schedule {
+			session := 1
+			if sessionCount > 0 {
+				session = sessionNumber(opt.PerformanceID)
+			}
+			rows = append(rows, []string{
+				opt.DateValue,
+				opt.PerformanceName,
+				strconv.Itoa(session),
+				strconv.Itoa(opt.AvailableCount),
+				strconv.Itoa(opt.MaybeCount),
+				strconv.Itoa(opt.UnavailableCount),
+				strconv.Itoa(opt.UnansweredCount),
+				strings.Join(opt.ConflictingUsers, "、"),
+			})
+		}
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be confirmed or suggested"})
+		return export.Table{}, false
+	}
+
+	return export.Table{Name: "スケジュール", Rows: rows}, true
+}
+
+// confirmedConflicts は確定スケジュールで同じ日付の別の演目にも参加するメンバーを返します
+func confirmedConflicts(users map[string]*models.UserData, perfID, dateID uint, datePerfs []uint) []string {
+	conflicts := make([]string, 0)
+	for name, userData := range users {
+		if !userData.Performances[perfID] {
+			continue
+		}
+		status := userData.Availability[dateID]
+		if status != models.StatusAvailable && status != models.StatusMaybe {
+			continue
+		}
+		for _, other := range datePerfs {
+			if other != perfID && userData.Performances[other] {
+				conflicts = append(conflicts, name)
+				break
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
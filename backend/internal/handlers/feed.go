@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+// feedHeartbeatInterval はプロキシに接続を切られないよう送るコメント行の間隔です
+const feedHeartbeatInterval = 25 * time.Second
+
+// StreamEventFeed は回答の追加・更新・削除と確定スケジュールの変更を
+// Server-Sent Events で配信します
+func (h *Handler) StreamEventFeed(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	messages, unsubscribe := h.hub.Subscribe(id)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(feedHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 接続直後に購読開始を通知する
+	c.SSEvent("ready", gin.H{"event_id": id})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg := <-messages:
+			c.SSEvent(msg.Type, msg.Data)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -1,40 +1,142 @@
 package handlers
 
 import (
-	"fmt"
-	"math/rand"
+	"context"
+	"errors"
 	"net/http"
-	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/access"
 	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/eventid"
+	"github.com/raie03/schedule-app/backend/internal/jobs"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/mail"
 	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/notify"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"github.com/raie03/schedule-app/backend/internal/webhooks"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Config holds handler settings
+type Config struct {
+	// OptimizeTimeout is the maximum duration of a synchronous optimization (0 = no limit)
+	OptimizeTimeout time.Duration
+	// Location is the time zone used to interpret Date values
+	Location *time.Location
+	// EventIDs generates new event IDs (the zero value uses the default length and alphabet)
+	EventIDs eventid.Generator
+	// Sessions issues viewer tokens for password-protected events (nil = random key per process)
+	Sessions *access.Signer
+	// Accounts issues account session tokens after a magic link login (nil = random key per process)
+	Accounts *access.Signer
+	// Calendars issues long-lived tokens for calendar subscription URLs (nil = random key per process)
+	Calendars *access.Signer
+	// Mail sends login links and notification emails (nil = write them to the log)
+	Mail mail.Sender
+	// AppURL is the frontend URL used in links sent by email
+	AppURL string
+	// MaxDates, MaxPerformances and MaxResponses cap the size of an event (0 = no limit)
+	MaxDates        int
+	MaxPerformances int
+	MaxResponses    int
+	// MaxSessions caps the number of practice sessions per performance in optimizations (0 or above 99 = 99)
+	MaxSessions int
+	// Webhooks delivers event notifications to subscribed URLs (nil = a dispatcher with default options)
+	Webhooks *webhooks.Dispatcher
+}
+
 // Handler handles HTTP requests
 type Handler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	jobs     *jobs.Manager
+	hub      *realtime.Hub
+	notifier *notify.Notifier
+	cfg      Config
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(db *gorm.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *gorm.DB, jobManager *jobs.Manager, cfg Config) *Handler {
+	if cfg.Sessions == nil {
+		cfg.Sessions = access.NewSigner(nil, access.PurposeViewer, 0)
+	}
+	if cfg.Accounts == nil {
+		cfg.Accounts = access.NewSigner(nil, access.PurposeAccount, access.DefaultAccountTTL)
+	}
+	if cfg.Calendars == nil {
+		cfg.Calendars = access.NewSigner(nil, access.PurposeCalendar, access.DefaultCalendarTTL)
+	}
+	if cfg.Mail == nil {
+		cfg.Mail = mail.LogSender{}
+	}
+	if cfg.Webhooks == nil {
+		cfg.Webhooks = webhooks.NewDispatcher(db, webhooks.Options{})
+	}
+	if cfg.MaxSessions <= 0 || cfg.MaxSessions > maxSessions {
+		cfg.MaxSessions = maxSessions
+	}
+	return &Handler{db: db, jobs: jobManager, hub: realtime.NewHub(), notifier: notify.New(cfg.Mail), cfg: cfg}
 }
 
-// generateEventID generates a unique ID for an event
-func generateEventID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	rand.Seed(time.Now().UnixNano())
+// publish はイベントの変更をリアルタイム配信の購読者と Webhook に通知します
+func (h *Handler) publish(ctx context.Context, eventID string, msg realtime.Message) {
+	h.hub.Publish(eventID, msg)
+	if err := h.cfg.Webhooks.Enqueue(eventID, msg.Type, msg.Data); err != nil {
+		logging.FromContext(ctx).Error("Failed to enqueue webhooks", "event_id", eventID, "type", msg.Type, "error", err)
+	}
+}
+
+// maxEventIDAttempts はイベントIDが衝突したときに再生成する回数の上限です
+const maxEventIDAttempts = 5
+
+// errEventIDExhausted はイベントIDの再生成が上限に達したことを表します
+var errEventIDExhausted = errors.New("could not generate a unique event ID")
 
-	length := 10
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+// insertEvent は event に新しいイベントIDを割り当て、日付・パフォーマンスとともに保存します
+// IDが既存のイベントと衝突した場合は、トランザクションを中断せずに別のIDで再試行します
+func (h *Handler) insertEvent(tx *gorm.DB, event *models.Event) error {
+	for attempt := 0; attempt < maxEventIDAttempts; attempt++ {
+		id, err := h.cfg.EventIDs.Generate()
+		if err != nil {
+			return err
+		}
+		event.ID = id
+
+		// 衝突した場合は何も挿入されず RowsAffected が 0 になる
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoNothing: true,
+		}).Omit(clause.Associations).Create(event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		for i := range event.Dates {
+			event.Dates[i].EventID = id
+		}
+		for i := range event.Performances {
+			event.Performances[i].EventID = id
+		}
+		if len(event.Dates) > 0 {
+			if err := tx.Create(&event.Dates).Error; err != nil {
+				return err
+			}
+		}
+		if len(event.Performances) > 0 {
+			if err := tx.Create(&event.Performances).Error; err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	return string(b)
+	return errEventIDExhausted
 }
 
 // CreateEvent creates a new event with performances
@@ -45,21 +147,80 @@ func (h *Handler) CreateEvent(c *gin.Context) {
 		return
 	}
 
+	// テンプレートから未指定の項目を補う
+	if req.TemplateID != nil {
+		template, ok := h.findTemplate(c, *req.TemplateID)
+		if !ok {
+			return
+		}
+		applyTemplate(&req, template)
+	}
+	// 団体のイベントはその団体のメンバーだけが作成できる
+	if req.OrganizationID != nil {
+		user, ok := h.requireUser(c)
+		if !ok {
+			return
+		}
+		if !h.isMember(*req.OrganizationID, user.ID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+	}
+	if req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	if len(req.Performances) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one performance is required"})
+		return
+	}
+	if !checkLimit(c, "performances", len(req.Performances), h.cfg.MaxPerformances) {
+		return
+	}
+	if req.UseRoster && req.OrganizationID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use_roster requires organization_id"})
+		return
+	}
+
+	// 繰り返しルールを展開し、重複した日付を除く
+	dateValues, _, err := expandDates(req.Dates, req.DateRules, nil, h.location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(dateValues) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one date is required"})
+		return
+	}
+	if !checkLimit(c, "dates", len(dateValues), h.cfg.MaxDates) {
+		return
+	}
+
 	// Create event
 	event := models.Event{
-		ID:          generateEventID(),
-		Title:       req.Title,
-		Description: req.Description,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Title:          req.Title,
+		Description:    req.Description,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		OrganizationID: req.OrganizationID,
+		UseRoster:      req.UseRoster,
+	}
+	applyDeadline(&event, req.DeadlineRequest)
+	if req.Password != "" {
+		hash, err := access.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		event.PasswordHash = hash
+		event.Protected = true
 	}
 
 	// Create dates
 	var dates []models.Date
-	for _, dateStr := range req.Dates {
+	for _, dateStr := range dateValues {
 		date := models.Date{
-			EventID: event.ID,
-			Value:   dateStr,
+			Value: dateStr,
 		}
 		dates = append(dates, date)
 	}
@@ -69,7 +230,6 @@ func (h *Handler) CreateEvent(c *gin.Context) {
 	var performances []models.Performance
 	for _, perfReq := range req.Performances {
 		perf := models.Performance{
-			EventID:     event.ID,
 			Title:       perfReq.Title,
 			Description: perfReq.Description,
 		}
@@ -78,13 +238,13 @@ func (h *Handler) CreateEvent(c *gin.Context) {
 	event.Performances = performances
 
 	// Save to database
-	tx := h.db.Begin()
-	if err := tx.Create(&event).Error; err != nil {
-		tx.Rollback()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return h.insertEvent(tx, &event)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
-	tx.Commit()
 
 	c.JSON(http.StatusCreated, event)
 }
@@ -112,24 +272,73 @@ func (h *Handler) AddResponse(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
+	if !checkOpen(c, event) {
+		return
+	}
 
 	var req models.CreateResponseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateAnswers(req.Answers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, memberID, ok := h.responseIdentity(c, event, req, 0)
+	if !ok {
+		return
+	}
 
 	// Create response
 	response := models.Response{
 		EventID:   id,
-		Name:      req.Name,
+		Name:      name,
 		CreatedAt: time.Now(),
+		MemberID:  memberID,
 	}
 
 	// Start transaction
 	tx := h.db.Begin()
+	responseCount, err := lockResponseCount(tx, id)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check responses"})
+		return
+	}
+	if !checkLimit(c, "responses", responseCount+1, h.cfg.MaxResponses) {
+		tx.Rollback()
+		return
+	}
+
+	// ログインしている場合は回答をアカウントに結び付け、同じイベントへの二重回答を防ぐ
+	// イベントをロックした後に確かめるため、同時に送られた回答も弾く
+	if userID, ok := h.currentUserID(c); ok {
+		var count int64
+		if err := tx.Model(&models.Response{}).Where("event_id = ? AND user_id = ?", id, userID).Count(&count).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check responses"})
+			return
+		}
+		if count > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already responded to this event"})
+			return
+		}
+		response.UserID = &userID
+	}
+	if memberID != nil && !checkMemberResponse(c, tx, id, *memberID, 0) {
+		tx.Rollback()
+		return
+	}
+
 	if err := tx.Create(&response).Error; err != nil {
 		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			h.respondDuplicateName(c, id, name, 0)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create response"})
 		return
 	}
@@ -168,6 +377,16 @@ func (h *Handler) AddResponse(c *gin.Context) {
 	}
 
 	tx.Commit()
+
+	// 購読中のクライアントに新しい回答を通知
+	response.Answers = answers
+	response.Performances = userPerformances
+	h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseCreated, Data: response})
+	if memberID != nil {
+		h.publishRosterCompleted(c.Request.Context(), event)
+	}
+	h.notifyResponseCreated(c.Request.Context(), event, response)
+
 	c.JSON(http.StatusCreated, gin.H{"message": "Response added successfully"})
 }
 
@@ -244,12 +463,13 @@ func (h *Handler) AnalyzeConflicts(c *gin.Context) {
 		}
 
 		availableUsers := make(map[string]userInfo)
+		keys := responseKeys(responses)
 
 		for _, response := range responses {
 			// Check if the user is available on this date
 			isAvailable := false
 			for _, answer := range response.Answers {
-				if answer.DateID == date.ID && (answer.Status == "available" || answer.Status == "maybe") {
+				if answer.DateID == date.ID && (answer.Status == models.StatusAvailable || answer.Status == models.StatusMaybe) {
 					isAvailable = true
 					break
 				}
@@ -262,8 +482,8 @@ func (h *Handler) AnalyzeConflicts(c *gin.Context) {
 					performanceIDs = append(performanceIDs, perf.PerformanceID)
 				}
 
-				availableUsers[response.Name] = userInfo{
-					Name:         response.Name,
+				availableUsers[keys[response.ID]] = userInfo{
+					Name:         keys[response.ID],
 					Performances: performanceIDs,
 				}
 			}
@@ -696,364 +916,34 @@ func (h *Handler) SuggestOptimalSchedule(c *gin.Context) {
 	id := c.Param("id")
 	startTime := time.Now() // パフォーマンス計測開始
 
-	// Get event with dates and performances - 必要なデータのみロード
-	var event models.Event
-	query := h.db.Preload("Dates").Preload("Performances")
-	if err := query.Where("id = ?", id).First(&event).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	opts, err := optimizerOptions(c.Query("moves"), c.Query("unanswered"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// データサイズの事前確保による最適化
-	// 初期容量を指定することでスライスの再割り当てを減らす
-	perfCount := len(event.Performances)
-	dateCount := len(event.Dates)
-
-	// 高速ルックアップのためのインデックスマップを作成
-	perfMap := make(map[uint]*models.Performance, perfCount)
-	dateMap := make(map[uint]*models.Date, dateCount)
-
-	for i := range event.Performances {
-		perfMap[event.Performances[i].ID] = &event.Performances[i]
-	}
-
-	for i := range event.Dates {
-		dateMap[event.Dates[i].ID] = &event.Dates[i]
-	}
-
-	// Get all responses with their performance selections and answers
-	var responses []models.Response
-	responseQuery := h.db.Preload("Answers").Preload("Performances")
-	if err := responseQuery.Where("event_id = ?", id).Find(&responses).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+	// クライアントの切断・期限切れで最適化を打ち切るためのコンテキスト
+	ctx, cancel, err := h.optimizeContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	defer cancel()
 
-	// データ前処理: パフォーマンス参加と日付可用性のマップを構築
-	// この前処理により、後のルックアップが O(1) 時間で行える
-	// type UserData struct {
-	// 	Name         string
-	// 	Performances map[uint]bool   // パフォーマンスID -> 参加するか
-	// 	Availability map[uint]string // 日付ID -> 可用性状態
-	// }
-
-	users := make(map[string]*models.UserData, len(responses))
-
-	for _, response := range responses {
-		userData := &models.UserData{
-			Name:         response.Name,
-			Performances: make(map[uint]bool, len(response.Performances)),
-			Availability: make(map[uint]string, len(response.Answers)),
-		}
-
-		// パフォーマンス参加情報をマップに格納
-		for _, perf := range response.Performances {
-			userData.Performances[perf.PerformanceID] = true
-		}
-
-		// 可用性情報をマップに格納
-		for _, answer := range response.Answers {
-			userData.Availability[answer.DateID] = answer.Status
-		}
-
-		users[response.Name] = userData
-	}
-
-	// 全ての日付×パフォーマンス組み合わせのスコアを一度に計算
-	// 二次元配列を使用して、頻繁なメモリアロケーションを避ける
-	type ScoreData struct {
-		AvailableCount   int
-		MaybeCount       int
-		UnavailableCount int
-		TotalCount       int
-		ConflictCount    int
-		WeightedScore    float64
-		ConflictingUsers []string
-	}
-
-	// スコアデータの二次元配列を初期化
-	scores := make([][]ScoreData, perfCount)
-	for i := range scores {
-		scores[i] = make([]ScoreData, dateCount)
-	}
-
-	// パフォーマンスと日付のマッピング用インデックス
-	perfIndex := make(map[uint]int, perfCount)
-	dateIndex := make(map[uint]int, dateCount)
-
-	for i, p := range event.Performances {
-		perfIndex[p.ID] = i
-	}
-
-	for i, d := range event.Dates {
-		dateIndex[d.ID] = i
-	}
-
-	// すべてのユーザーについて一度だけ処理することで、O(n³)からO(n²)に計算量を削減
-	for _, userData := range users {
-		// このユーザーが参加するパフォーマンスについて
-		userPerfs := make([]uint, 0, len(userData.Performances))
-		for perfID := range userData.Performances {
-			userPerfs = append(userPerfs, perfID)
-		}
-
-		hasMultiplePerfs := len(userPerfs) > 1
-
-		// このユーザーの各パフォーマンスと各日付の組み合わせをチェック
-		for _, perfID := range userPerfs {
-			pIdx := perfIndex[perfID]
-
-			// このユーザーの各日付での可用性をチェック
-			for dateID, status := range userData.Availability {
-				dIdx, ok := dateIndex[dateID]
-				if !ok {
-					continue // 無効な日付IDはスキップ
-				}
-
-				scoreData := &scores[pIdx][dIdx]
-				scoreData.TotalCount++
-
-				// 可用性に応じてカウントとスコアを更新
-				switch status {
-				case "available":
-					scoreData.AvailableCount++
-					scoreData.WeightedScore += 1.0
-				case "maybe":
-					scoreData.MaybeCount++
-					scoreData.WeightedScore += 0.5
-				default:
-					scoreData.UnavailableCount++
-				}
-
-				// 複数パフォーマンスに参加する場合は潜在的コンフリクト
-				if hasMultiplePerfs {
-					scoreData.ConflictCount++
-					if !containsString(scoreData.ConflictingUsers, userData.Name) {
-						scoreData.ConflictingUsers = append(scoreData.ConflictingUsers, userData.Name)
-					}
-				}
-			}
-		}
-	}
-
-	// // すべてのユーザーについて一度だけ処理する前に、日付ごとのデータ構造を初期化
-	// dateAssignments := make(map[uint]*DateAssignment)
-	// for _, date := range event.Dates {
-	// 	dateAssignments[date.ID] = &DateAssignment{
-	// 		PerformanceIDs: make(map[uint]bool),
-	// 		UserPerfs:      make(map[string]map[uint]bool),
-	// 	}
-	// }
-
-	// // ユーザー参加データを日付ごとに整理
-	// for _, userData := range users {
-	// 	// 各日付に対してこのユーザーの参加可能なパフォーマンスを追跡
-	// 	for dateID, status := range userData.Availability {
-	// 		if status != "available" && status != "maybe" {
-	// 			continue // 参加不可能な場合はスキップ
-	// 		}
-
-	// 		// この日付のアサインメントデータを取得
-	// 		assignment, ok := dateAssignments[dateID]
-	// 		if !ok {
-	// 			continue
-	// 		}
-
-	// 		// このユーザーがこの日に参加できるパフォーマンス
-	// 		if _, exists := assignment.UserPerfs[userData.Name]; !exists {
-	// 			assignment.UserPerfs[userData.Name] = make(map[uint]bool)
-	// 		}
-
-	// 		// ユーザーが参加するパフォーマンスを追加
-	// 		for perfID := range userData.Performances {
-	// 			assignment.UserPerfs[userData.Name][perfID] = true
-	// 			assignment.PerformanceIDs[perfID] = true
-	// 		}
-	// 	}
-	// }
-
-	// // スコアデータの計算
-	// for pIdx, perfScores := range scores {
-	// 	perfID := event.Performances[pIdx].ID
-
-	// 	for dIdx := range perfScores {
-	// 		dateID := event.Dates[dIdx].ID
-	// 		assignment := dateAssignments[dateID]
-
-	// 		// コンフリクトするユーザーを特定
-	// 		conflictingUsers := make([]string, 0)
-
-	// 		for userName, userPerfs := range assignment.UserPerfs {
-	// 			// このユーザーが現在のパフォーマンスに参加するか
-	// 			if !userPerfs[perfID] {
-	// 				continue
-	// 			}
-
-	// 			// このユーザーが他のパフォーマンスにも参加するかチェック
-	// 			otherPerfCount := 0
-	// 			for otherPerfID := range userPerfs {
-	// 				if otherPerfID != perfID {
-	// 					otherPerfCount++
-	// 				}
-	// 			}
-
-	// 			//fmt.Println(otherPerfCount)
-
-	// 			// 同じ日に2つ以上のパフォーマンスに参加する場合のみコンフリクト
-	// 			if otherPerfCount > 0 {
-	// 				conflictingUsers = append(conflictingUsers, userName)
-	// 			}
-	// 		}
-
-	// 		// コンフリクト情報を更新
-	// 		scores[pIdx][dIdx].ConflictCount = len(conflictingUsers)
-	// 		scores[pIdx][dIdx].ConflictingUsers = conflictingUsers
-
-	// 		fmt.Println(pIdx)
-	// 		fmt.Println(dIdx)
-	// 		fmt.Println(scores[pIdx][dIdx].ConflictCount)
-	// 		fmt.Println(scores[pIdx][dIdx].ConflictingUsers)
-	// 	}
-	// }
-
-	allOptions := make([]models.ScoredOption, 0, perfCount*dateCount)
-
-	// 全ての組み合わせをフラットなリストに変換
-	for pIdx, perfScores := range scores {
-		perfID := event.Performances[pIdx].ID
-		perfName := event.Performances[pIdx].Title
-
-		for dIdx, score := range perfScores {
-			dateID := event.Dates[dIdx].ID
-			dateValue := event.Dates[dIdx].Value
-
-			option := models.ScoredOption{
-				PerformanceID:    perfID,
-				DateID:           dateID,
-				PerformanceName:  perfName,
-				DateValue:        dateValue,
-				AvailableCount:   score.AvailableCount,
-				MaybeCount:       score.MaybeCount,
-				UnavailableCount: score.UnavailableCount,
-				TotalCount:       score.TotalCount,
-				ConflictCount:    score.ConflictCount,
-				WeightedScore:    score.WeightedScore,
-				ConflictingUsers: score.ConflictingUsers,
-			}
-
-			allOptions = append(allOptions, option)
-		}
-	}
-
-	// スコアの高い順にソート - クイックソートを利用
-	sort.Slice(allOptions, func(i, j int) bool {
-		// 主要ソート基準: 重み付きスコア (高いほど良い)
-		if allOptions[i].WeightedScore != allOptions[j].WeightedScore {
-			return allOptions[i].WeightedScore > allOptions[j].WeightedScore
-		}
-
-		// 二次ソート基準: コンフリクト数 (少ないほど良い)
-		if allOptions[i].ConflictCount != allOptions[j].ConflictCount {
-			return allOptions[i].ConflictCount < allOptions[j].ConflictCount
-		}
-
-		// 三次ソート基準: available人数 (多いほど良い)
-		if allOptions[i].AvailableCount != allOptions[j].AvailableCount {
-			return allOptions[i].AvailableCount > allOptions[j].AvailableCount
-		}
-
-		// 四次ソート基準: maybe人数 (多いほど良い)
-		return allOptions[i].MaybeCount > allOptions[j].MaybeCount
-	})
-
-	// 最適なスケジュールの構築 - 高速なルックアップのためにマップを使用
-	assignedPerfs := make(map[uint]bool, perfCount)
-	assignedDates := make(map[uint]bool, dateCount)
-	var bestSchedule []models.ScoredOption
-
-	// まずは日付の重複を避けてスケジュール
-	for _, option := range allOptions {
-		// すでに割り当て済みのパフォーマンスや日付はスキップ
-		if assignedPerfs[option.PerformanceID] || assignedDates[option.DateID] {
-			continue
-		}
-
-		// 割り当て
-		assignedPerfs[option.PerformanceID] = true
-		assignedDates[option.DateID] = true
-		bestSchedule = append(bestSchedule, option)
-
-		// すべてのパフォーマンスがスケジュールされたら終了
-		if len(assignedPerfs) == perfCount {
-			break
-		}
-	}
-
-	// 日付の重複を許容しても割り当てられなかったパフォーマンスがあれば対応
-	if len(assignedPerfs) < perfCount {
-		// 日付の割り当てをリセット、パフォーマンスの割り当ては維持
-		for _, option := range allOptions {
-			// すでに割り当て済みのパフォーマンスはスキップ
-			if assignedPerfs[option.PerformanceID] {
-				continue
-			}
-
-			// 割り当て (日付の重複を許容)
-			assignedPerfs[option.PerformanceID] = true
-			bestSchedule = append(bestSchedule, option)
-
-			// すべてのパフォーマンスがスケジュールされたら終了
-			if len(assignedPerfs) == perfCount {
-				break
-			}
-		}
+	// イベント・回答を読み込み、全ての日付×パフォーマンス組み合わせのスコアを計算
+	input, ok := h.loadOptimizationInput(c, id, 0)
+	if !ok {
+		return
 	}
 
-	// 全体のスコアと統計を計算
-	var totalWeightedScore float64
-	var totalConflicts int
-	var totalAvailable int
-	var totalMaybe int
-	var totalUnavailable int
-
-	// 3. グローバル最適化（改善フェーズ）
-	// - 遺伝的アルゴリズム
-	// - 焼きなまし法
-	// - もしくは他のメタヒューリスティクス
-	//fmt.Println(totalConflicts)
-	optimizedSchedule := algorithm.OptimizeSchedule(allOptions, perfCount, dateCount, users)
-
-	// 4. コンフリクト分析と必要に応じた微調整
-	// finalSchedule := refineSchedule(optimizedSchedule, users)
-
-	// 5. 複数の代替スケジュールの提案
-	// alternativeSchedules := generateAlternatives(allOptions, finalSchedule, perfCount, dateCount)
+	// グローバル最適化（焼きなまし法）
+	result := algorithm.Optimize(ctx, input.Options, input.PerfCount, input.Users, opts)
 
 	// 計算時間と統計情報を計測
 	elapsedTime := time.Since(startTime)
-	fmt.Println(elapsedTime)
-
-	for _, opt := range optimizedSchedule {
-		totalWeightedScore += opt.WeightedScore
-		totalConflicts += opt.ConflictCount
-		totalAvailable += opt.AvailableCount
-		totalMaybe += opt.MaybeCount
-		totalUnavailable += opt.UnavailableCount
-	}
-	// 6. 結果の返却
-	c.JSON(http.StatusOK, gin.H{
-		"suggested_schedule": optimizedSchedule,
-		"metrics": gin.H{
-			"total_weighted_score":   totalWeightedScore,
-			"total_conflicts":        totalConflicts,
-			"total_available":        totalAvailable,
-			"total_maybe":            totalMaybe,
-			"total_unavailable":      totalUnavailable,
-			"performance_count":      perfCount,
-			"scheduled_performances": len(bestSchedule),
-			"computation_time_ms":    float64(elapsedTime.Microseconds()) / 1000.0,
-		},
-	})
+	logOptimization(ctx, input, result, elapsedTime)
+
+	c.JSON(http.StatusOK, optimizationResponse(result, input.PerfCount, elapsedTime))
 }
 
 // SuggestOptimalMultiSessionSchedule は複数の練習セッションに対する最適スケジュールを提案します
@@ -1066,312 +956,36 @@ func (h *Handler) SuggestOptimalMultiSessionSchedule(c *gin.Context) {
 	if err != nil || sessionCount < 1 {
 		sessionCount = 3 // デフォルト値
 	}
-
-	// イベント、日付、パフォーマンスを取得
-	var event models.Event
-	query := h.db.Preload("Dates").Preload("Performances")
-	if err := query.Where("id = ?", id).First(&event).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	if !h.checkSessions(c, sessionCount) {
 		return
 	}
 
-	// レスポンスを取得
-	var responses []models.Response
-	responseQuery := h.db.Preload("Answers").Preload("Performances")
-	if err := responseQuery.Where("event_id = ?", id).Find(&responses).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+	opts, err := optimizerOptions(c.Query("moves"), c.Query("unanswered"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// オリジナルのパフォーマンス数
-	origPerfCount := len(event.Performances)
-	dateCount := len(event.Dates)
-
-	// 練習回数分にパフォーマンスを拡張
-	expandedPerfs := algorithm.ExpandPerformancesForMultipleSessions(event.Performances, sessionCount)
-	expandedPerfCount := len(expandedPerfs)
-
-	// ユーザーデータの処理
-	users := make(map[string]*models.UserData, len(responses))
-	for _, response := range responses {
-		userData := &models.UserData{
-			Name:         response.Name,
-			Performances: make(map[uint]bool),
-			Availability: make(map[uint]string),
-		}
-
-		// パフォーマンス参加情報
-		for _, perf := range response.Performances {
-			// オリジナルのパフォーマンス参加情報を全練習セッションに適用
-			origPerfID := perf.PerformanceID
-			for i := 1; i <= sessionCount; i++ {
-				sessionPerfID := origPerfID*100 + uint(i)
-				userData.Performances[sessionPerfID] = true
-			}
-		}
-
-		// 可用性情報
-		for _, answer := range response.Answers {
-			userData.Availability[answer.DateID] = answer.Status
-		}
-
-		users[response.Name] = userData
-	}
-
-	// スコア計算（既存のコードを流用）
-	// ※ここで拡張されたパフォーマンスIDを使用する必要があります
-	// 全ての日付×パフォーマンス組み合わせのスコアを一度に計算
-	// 二次元配列を使用して、頻繁なメモリアロケーションを避ける
-	type ScoreData struct {
-		AvailableCount   int
-		MaybeCount       int
-		UnavailableCount int
-		TotalCount       int
-		ConflictCount    int
-		WeightedScore    float64
-		ConflictingUsers []string
-	}
-
-	// スコアデータの二次元配列を初期化
-	scores := make([][]ScoreData, expandedPerfCount)
-	for i := range scores {
-		scores[i] = make([]ScoreData, dateCount)
-	}
-
-	// パフォーマンスと日付のマッピング用インデックス
-	perfIndex := make(map[uint]int, expandedPerfCount)
-	dateIndex := make(map[uint]int, dateCount)
-
-	for i, p := range expandedPerfs {
-		perfIndex[p.ID] = i
-	}
-
-	for i, d := range event.Dates {
-		dateIndex[d.ID] = i
-	}
-
-	// すべてのユーザーについて一度だけ処理することで、O(n³)からO(n²)に計算量を削減
-	for _, userData := range users {
-		// このユーザーが参加するパフォーマンスについて
-		userPerfs := make([]uint, 0, len(userData.Performances))
-		for perfID := range userData.Performances {
-			userPerfs = append(userPerfs, perfID)
-		}
-
-		hasMultiplePerfs := len(userPerfs) > 1
-
-		// このユーザーの各パフォーマンスと各日付の組み合わせをチェック
-		for _, perfID := range userPerfs {
-			pIdx := perfIndex[perfID]
-
-			// このユーザーの各日付での可用性をチェック
-			for dateID, status := range userData.Availability {
-				dIdx, ok := dateIndex[dateID]
-				if !ok {
-					continue // 無効な日付IDはスキップ
-				}
-
-				scoreData := &scores[pIdx][dIdx]
-				scoreData.TotalCount++
-
-				// 可用性に応じてカウントとスコアを更新
-				switch status {
-				case "available":
-					scoreData.AvailableCount++
-					scoreData.WeightedScore += 1.0
-				case "maybe":
-					scoreData.MaybeCount++
-					scoreData.WeightedScore += 0.5
-				default:
-					scoreData.UnavailableCount++
-				}
-
-				// 複数パフォーマンスに参加する場合は潜在的コンフリクト
-				if hasMultiplePerfs {
-					scoreData.ConflictCount++
-					if !containsString(scoreData.ConflictingUsers, userData.Name) {
-						scoreData.ConflictingUsers = append(scoreData.ConflictingUsers, userData.Name)
-					}
-				}
-			}
-		}
-	}
-
-	allOptions := make([]models.ScoredOption, 0, expandedPerfCount*dateCount)
-
-	// 全ての組み合わせをフラットなリストに変換
-	for pIdx, perfScores := range scores {
-		perfID := expandedPerfs[pIdx].ID
-		perfName := expandedPerfs[pIdx].Title
-
-		for dIdx, score := range perfScores {
-			dateID := event.Dates[dIdx].ID
-			dateValue := event.Dates[dIdx].Value
-
-			option := models.ScoredOption{
-				PerformanceID:    perfID,
-				DateID:           dateID,
-				PerformanceName:  perfName,
-				DateValue:        dateValue,
-				AvailableCount:   score.AvailableCount,
-				MaybeCount:       score.MaybeCount,
-				UnavailableCount: score.UnavailableCount,
-				TotalCount:       score.TotalCount,
-				ConflictCount:    score.ConflictCount,
-				WeightedScore:    score.WeightedScore,
-				ConflictingUsers: score.ConflictingUsers,
-			}
-
-			allOptions = append(allOptions, option)
-		}
-	}
-
-	// スコアの高い順にソート - クイックソートを利用
-	sort.Slice(allOptions, func(i, j int) bool {
-		// 主要ソート基準: 重み付きスコア (高いほど良い)
-		if allOptions[i].WeightedScore != allOptions[j].WeightedScore {
-			return allOptions[i].WeightedScore > allOptions[j].WeightedScore
-		}
-
-		// 二次ソート基準: コンフリクト数 (少ないほど良い)
-		if allOptions[i].ConflictCount != allOptions[j].ConflictCount {
-			return allOptions[i].ConflictCount < allOptions[j].ConflictCount
-		}
-
-		// 三次ソート基準: available人数 (多いほど良い)
-		if allOptions[i].AvailableCount != allOptions[j].AvailableCount {
-			return allOptions[i].AvailableCount > allOptions[j].AvailableCount
-		}
-
-		// 四次ソート基準: maybe人数 (多いほど良い)
-		return allOptions[i].MaybeCount > allOptions[j].MaybeCount
-	})
-
-	// 最適なスケジュールの構築 - 高速なルックアップのためにマップを使用
-	assignedPerfs := make(map[uint]bool, expandedPerfCount)
-	assignedDates := make(map[uint]bool, dateCount)
-	var bestSchedule []models.ScoredOption
-
-	// まずは日付の重複を避けてスケジュール
-	for _, option := range allOptions {
-		// すでに割り当て済みのパフォーマンスや日付はスキップ
-		if assignedPerfs[option.PerformanceID] || assignedDates[option.DateID] {
-			continue
-		}
-
-		// 割り当て
-		assignedPerfs[option.PerformanceID] = true
-		assignedDates[option.DateID] = true
-		bestSchedule = append(bestSchedule, option)
-
-		// すべてのパフォーマンスがスケジュールされたら終了
-		if len(assignedPerfs) == expandedPerfCount {
-			break
-		}
+	ctx, cancel, err := h.optimizeContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	defer cancel()
 
-	// 日付の重複を許容しても割り当てられなかったパフォーマンスがあれば対応
-	if len(assignedPerfs) < expandedPerfCount {
-		// 日付の割り当てをリセット、パフォーマンスの割り当ては維持
-		for _, option := range allOptions {
-			// すでに割り当て済みのパフォーマンスはスキップ
-			if assignedPerfs[option.PerformanceID] {
-				continue
-			}
-
-			// 割り当て (日付の重複を許容)
-			assignedPerfs[option.PerformanceID] = true
-			bestSchedule = append(bestSchedule, option)
-
-			// すべてのパフォーマンスがスケジュールされたら終了
-			if len(assignedPerfs) == expandedPerfCount {
-				break
-			}
-		}
+	// 練習回数分にパフォーマンスを拡張してスコアを計算
+	input, ok := h.loadOptimizationInput(c, id, sessionCount)
+	if !ok {
+		return
 	}
 
 	// スケジュール最適化
-	optimizedSchedule := algorithm.OptimizeScheduleWithMultipleSessions(
-		allOptions, origPerfCount, dateCount, sessionCount, users)
-
-	// 結果をセッションごとにグループ化
-	// sessionSchedules := make(map[int][]models.ScoredOption)
-	// for _, opt := range optimizedSchedule {
-	// 	// パフォーマンスIDから元のIDとセッション番号を抽出
-	// 	origPerfID := opt.PerformanceID / 100
-	// 	sessionNum := int(opt.PerformanceID % 100)
-
-	// 	// 結果をセッション番号ごとにグループ化
-	// 	sessionOpt := opt
-	// 	sessionOpt.PerformanceID = origPerfID // 元のパフォーマンスIDに戻す
-
-	// 	if sessionSchedules[sessionNum] == nil {
-	// 		sessionSchedules[sessionNum] = make([]models.ScoredOption, 0)
-	// 	}
-	// 	sessionSchedules[sessionNum] = append(sessionSchedules[sessionNum], sessionOpt)
-	// }
-
-	// // レスポンス用に整形
-	// result := make([]map[string]interface{}, 0, sessionCount)
-	// for i := 1; i <= sessionCount; i++ {
-	// 	schedule := sessionSchedules[i]
-
-	// 各セッションのメトリクスを計算
-	var totalWeightedScore float64
-	var totalConflicts int
-	var totalAvailable int
-	var totalMaybe int
-	var totalUnavailable int
-
-	// 	for _, opt := range schedule {
-	// 		totalWeightedScore += opt.WeightedScore
-	// 		totalConflicts += opt.ConflictCount
-	// 		totalAvailable += opt.AvailableCount
-	// 		totalMaybe += opt.MaybeCount
-	// 		totalUnavailable += opt.UnavailableCount
-	// 	}
-
-	// 	result = append(result, map[string]interface{}{
-	// 		"session":  i,
-	// 		"schedule": schedule,
-	// 		"metrics": map[string]interface{}{
-	// 			"total_weighted_score": totalWeightedScore,
-	// 			"total_conflicts":      totalConflicts,
-	// 			"total_available":      totalAvailable,
-	// 			"total_maybe":          totalMaybe,
-	// 			"total_unavailable":    totalUnavailable,
-	// 		},
-	// 	})
-	// }
+	result := algorithm.OptimizeWithMultipleSessions(ctx,
+		input.Options, len(input.Event.Performances), sessionCount, input.Users, opts)
 
 	// 計算時間を計測
 	elapsedTime := time.Since(startTime)
+	logOptimization(ctx, input, result, elapsedTime)
 
-	// // 結果をクライアントに返却
-	// c.JSON(http.StatusOK, gin.H{
-	// 	"multi_session_schedules": result,
-	// 	"session_count":           sessionCount,
-	// 	"computation_time_ms":     float64(elapsedTime.Microseconds()) / 1000.0,
-	// })
-	for _, opt := range optimizedSchedule {
-		totalWeightedScore += opt.WeightedScore
-		totalConflicts += opt.ConflictCount
-		totalAvailable += opt.AvailableCount
-		totalMaybe += opt.MaybeCount
-		totalUnavailable += opt.UnavailableCount
-	}
-	// 6. 結果の返却
-	c.JSON(http.StatusOK, gin.H{
-		"suggested_schedule": optimizedSchedule,
-		"metrics": gin.H{
-			"total_weighted_score":   totalWeightedScore,
-			"total_conflicts":        totalConflicts,
-			"total_available":        totalAvailable,
-			"total_maybe":            totalMaybe,
-			"total_unavailable":      totalUnavailable,
-			"performance_count":      expandedPerfCount,
-			"scheduled_performances": len(bestSchedule),
-			"computation_time_ms":    float64(elapsedTime.Microseconds()) / 1000.0,
-		},
-	})
+	c.JSON(http.StatusOK, optimizationResponse(result, input.PerfCount, elapsedTime))
 }
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/ical"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+)
+
+// location は Date.Value を解釈するタイムゾーンを返します
+func (h *Handler) location() *time.Location {
+	if h.cfg.Location != nil {
+		return h.cfg.Location
+	}
+	return timeslot.LoadLocation("")
+}
+
+// sessionUID は確定したセッションの iCalendar の UID を返します
+// イベント・パフォーマンス・セッション番号から作るため、日付を変更して再インポートしても
+// カレンダー上の予定が重複せず更新されます
+func sessionUID(eventID string, s models.ScheduledSession) string {
+	return fmt.Sprintf("%s-%d-%d@schedule-app", eventID, s.PerformanceID, s.Session)
+}
+
+// scheduleCalendar は確定スケジュールを iCalendar に変換します
+// エクスポート・購読フィード・通知メールの添付はすべてここで作ります
+func (h *Handler) scheduleCalendar(name string, event models.Event, sessions []models.ScheduledSession) ical.Calendar {
+	// パフォーマンスごとのセッション数（複数ある場合のみタイトルに回数を付ける）
+	sessionCounts := make(map[uint]int)
+	for _, s := range sessions {
+		sessionCounts[s.PerformanceID]++
+	}
+
+	cal := ical.Calendar{Name: name}
+	for _, s := range sessions {
+		slot, err := timeslot.Parse(s.Date.Value, h.location())
+		if err != nil {
+			continue // 解釈できない日付はスキップ
+		}
+
+		summary := s.Performance.Title
+		if sessionCounts[s.PerformanceID] > 1 {
+			summary = fmt.Sprintf("%s (練習%d)", s.Performance.Title, s.Session)
+		}
+
+		cal.Events = append(cal.Events, ical.Event{
+			UID:          sessionUID(event.ID, s),
+			Summary:      summary,
+			Description:  event.Title,
+			Start:        slot.Start,
+			End:          slot.End,
+			LastModified: s.CreatedAt,
+		})
+	}
+	return cal
+}
+
+// memberSessions は確定スケジュールのうち、回答者が参加するパフォーマンスのセッションを返します
+func (h *Handler) memberSessions(responseID uint, sessions []models.ScheduledSession) ([]models.ScheduledSession, error) {
+	var userPerformances []models.UserPerformance
+	if err := h.db.Where("response_id = ?", responseID).Find(&userPerformances).Error; err != nil {
+		return nil, err
+	}
+	joined := make(map[uint]bool, len(userPerformances))
+	for _, up := range userPerformances {
+		joined[up.PerformanceID] = true
+	}
+
+	memberSessions := make([]models.ScheduledSession, 0, len(sessions))
+	for _, s := range sessions {
+		if joined[s.PerformanceID] {
+			memberSessions = append(memberSessions, s)
+		}
+	}
+	return memberSessions, nil
+}
+
+// ExportScheduleICS exports the confirmed schedule as an iCalendar file
+func (h *Handler) ExportScheduleICS(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	sessions, err := h.loadConfirmedSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedule"})
+		return
+	}
+
+	cal := h.scheduleCalendar(event.Title, event, sessions)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ics"`, event.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Encode())
+}
+
+// ExportMemberScheduleICS exports only the sessions of the performances a member joins
+func (h *Handler) ExportMemberScheduleICS(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	response, ok := h.findResponse(c, id)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.loadConfirmedSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedule"})
+		return
+	}
+
+	memberSessions, err := h.memberSessions(response.ID, sessions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get performances"})
+		return
+	}
+
+	cal := h.scheduleCalendar(fmt.Sprintf("%s (%s)", event.Title, response.Name), event, memberSessions)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%d.ics"`, event.ID, response.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Encode())
+}
@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/export"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// インポート結果の各行の処理内容
+const (
+	importCreate    = "create"
+	importUpdate    = "update"
+	importUnchanged = "unchanged"
+)
+
+// importStatuses は CSV の回答欄に書ける値です（エクスポートの記号と英語表記）
+var importStatuses = map[string]models.AnswerStatus{
+	"○":           models.StatusAvailable,
+	"◯":           models.StatusAvailable,
+	"available":   models.StatusAvailable,
+	"△":           models.StatusMaybe,
+	"maybe":       models.StatusMaybe,
+	"×":           models.StatusUnavailable,
+	"✕":           models.StatusUnavailable,
+	"x":           models.StatusUnavailable,
+	"unavailable": models.StatusUnavailable,
+	"未回答":         models.StatusUnanswered,
+	"unanswered":  models.StatusUnanswered,
+}
+
+// performanceListHeaders は演目名を区切って並べる列の見出しです
+var performanceListHeaders = map[string]bool{
+	"演目":           true,
+	"performances": true,
+}
+
+// importAnswerChange は1つの日付の回答の変更です
+type importAnswerChange struct {
+	DateID uint                `json:"date_id"`
+	Date   string              `json:"date"`
+	From   models.AnswerStatus `json:"from,omitempty"`
+	To     models.AnswerStatus `json:"to"`
+}
+
+// importRow は CSV 1行分の取り込み内容と既存の回答との差分です
+type importRow struct {
+	Line                int                  `json:"line"`
+	Name                string               `json:"name"`
+	Action              string               `json:"action"`
+	ResponseID          uint                 `json:"response_id,omitempty"`
+	Answers             []importAnswerChange `json:"answers,omitempty"`
+	AddedPerformances   []string             `json:"added_performances,omitempty"`
+	RemovedPerformances []string             `json:"removed_performances,omitempty"`
+
+	answers      map[uint]models.AnswerStatus // 取り込み後の回答
+	performances []uint                       // 取り込み後の参加演目
+}
+
+// importError は CSV の検証エラーです
+type importError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// responseCSV は見出し行から解釈した列の対応です
+type responseCSV struct {
+	dateCols    []dateColumn
+	perfCols    []perfColumn
+	listCols    []int
+	perfByTitle map[string]models.Performance
+}
+
+// dateColumn は日付の列です
+type dateColumn struct {
+	index int
+	date  models.Date
+}
+
+// perfColumn は演目ごとの参加欄の列です
+type perfColumn struct {
+	index int
+	perf  models.Performance
+}
+
+// hasPerformances は CSV に参加演目の列があるかを返します
+// ない場合は既存の参加演目をそのまま残します
+func (rc responseCSV) hasPerformances() bool {
+	return len(rc.perfCols) > 0 || len(rc.listCols) > 0
+}
+
+// parseResponseHeader は見出し行を日付・演目の列に対応付けます
+// 1列目は名前です。日付の列は Date.Value、演目の列は演目名と一致させます
+func parseResponseHeader(event models.Event, header []string) (responseCSV, []importError) {
+	rc := responseCSV{
+		perfByTitle: make(map[string]models.Performance, len(event.Performances)),
+	}
+	dateByValue := make(map[string]models.Date, len(event.Dates))
+	for _, date := range event.Dates {
+		dateByValue[date.Value] = date
+	}
+	for _, perf := range event.Performances {
+		rc.perfByTitle[perf.Title] = perf
+	}
+
+	var errs []importError
+	seen := make(map[string]bool)
+	for i := 1; i < len(header); i++ {
+		label := strings.TrimSpace(header[i])
+		if label == "" {
+			continue
+		}
+		if seen[label] {
+			errs = append(errs, importError{Line: 1, Message: fmt.Sprintf("duplicate column %q", label)})
+			continue
+		}
+		seen[label] = true
+
+		if date, ok := dateByValue[label]; ok {
+			rc.dateCols = append(rc.dateCols, dateColumn{index: i, date: date})
+		} else if perf, ok := rc.perfByTitle[label]; ok {
+			rc.perfCols = append(rc.perfCols, perfColumn{index: i, perf: perf})
+		} else if performanceListHeaders[strings.ToLower(label)] {
+			rc.listCols = append(rc.listCols, i)
+		} else {
+			errs = append(errs, importError{Line: 1, Message: fmt.Sprintf("unknown column %q", label)})
+		}
+	}
+	return rc, errs
+}
+
+// parseResponseRows は CSV を検証し、既存の回答との差分を作成します
+// 空欄の日付は既存の回答を残し、参加演目の列がある場合は参加演目を置き換えます
+func parseResponseRows(event models.Event, existing []models.Response, rows [][]string) ([]importRow, []importError) {
+	if len(rows) == 0 {
+		return nil, []importError{{Line: 1, Message: "empty CSV"}}
+	}
+
+	rc, errs := parseResponseHeader(event, rows[0])
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// 名前（models.NormalizeName で比較）が同じ回答が複数ある場合は、どれを更新するか決められないためエラーにする
+	byName := make(map[string][]models.Response, len(existing))
+	for _, response := range existing {
+		key := models.NormalizeName(response.Name)
+		byName[key] = append(byName[key], response)
+	}
+	dateValues := make(map[uint]string, len(event.Dates))
+	for _, date := range event.Dates {
+		dateValues[date.ID] = date.Value
+	}
+	perfTitles := make(map[uint]string, len(event.Performances))
+	for _, perf := range event.Performances {
+		perfTitles[perf.ID] = perf.Title
+	}
+
+	var result []importRow
+	seenNames := make(map[string]int)
+	for i, record := range rows[1:] {
+		line := i + 2
+		if isBlankRecord(record) {
+			continue
+		}
+
+		name := strings.TrimSpace(record[0])
+		if name == "" {
+			errs = append(errs, importError{Line: line, Message: "name is required"})
+			continue
+		}
+		key := models.NormalizeName(name)
+		if prev, ok := seenNames[key]; ok {
+			errs = append(errs, importError{Line: line, Message: fmt.Sprintf("%q is also on line %d", name, prev)})
+			continue
+		}
+		seenNames[key] = line
+
+		matches := byName[key]
+		if len(matches) > 1 {
+			errs = append(errs, importError{Line: line, Message: fmt.Sprintf("%q matches %d existing responses; merge them before importing", name, len(matches))})
+			continue
+		}
+
+		row := importRow{Line: line, Name: name, Action: importCreate, answers: make(map[uint]models.AnswerStatus)}
+		oldPerfs := make(map[uint]bool)
+		if len(matches) == 1 {
+			current := matches[0]
+			row.Action = importUnchanged
+			row.ResponseID = current.ID
+			row.Name = current.Name // 表記ゆれがあっても既存の回答の名前を保つ
+			for _, answer := range current.Answers {
+				row.answers[answer.DateID] = answer.Status
+			}
+			for _, up := range current.Performances {
+				oldPerfs[up.PerformanceID] = true
+			}
+		}
+		oldAnswers := make(map[uint]models.AnswerStatus, len(row.answers))
+		for dateID, status := range row.answers {
+			oldAnswers[dateID] = status
+		}
+
+		// 回答
+		for _, dc := range rc.dateCols {
+			date := dc.date
+			cell := strings.TrimSpace(cellAt(record, dc.index))
+			if cell == "" {
+				continue
+			}
+			status, ok := importStatuses[strings.ToLower(cell)]
+			if !ok {
+				errs = append(errs, importError{Line: line, Message: fmt.Sprintf("invalid status %q for %s", cell, date.Value)})
+				continue
+			}
+			row.answers[date.ID] = status
+		}
+
+		// 参加演目
+		newPerfs := oldPerfs
+		if rc.hasPerformances() {
+			newPerfs = make(map[uint]bool)
+			for _, pc := range rc.perfCols {
+				if strings.TrimSpace(cellAt(record, pc.index)) != "" {
+					newPerfs[pc.perf.ID] = true
+				}
+			}
+			for _, col := range rc.listCols {
+				for _, title := range splitTitles(cellAt(record, col)) {
+					perf, ok := rc.perfByTitle[title]
+					if !ok {
+						errs = append(errs, importError{Line: line, Message: fmt.Sprintf("unknown performance %q", title)})
+						continue
+					}
+					newPerfs[perf.ID] = true
+				}
+			}
+		}
+		for perfID := range newPerfs {
+			row.performances = append(row.performances, perfID)
+		}
+		sort.Slice(row.performances, func(a, b int) bool { return row.performances[a] < row.performances[b] })
+
+		// 差分
+		for _, date := range event.Dates {
+			if to, ok := row.answers[date.ID]; ok && to != oldAnswers[date.ID] {
+				row.Answers = append(row.Answers, importAnswerChange{DateID: date.ID, Date: dateValues[date.ID], From: oldAnswers[date.ID], To: to})
+			}
+		}
+		for _, perf := range event.Performances {
+			switch {
+			case newPerfs[perf.ID] && !oldPerfs[perf.ID]:
+				row.AddedPerformances = append(row.AddedPerformances, perfTitles[perf.ID])
+			case !newPerfs[perf.ID] && oldPerfs[perf.ID]:
+				row.RemovedPerformances = append(row.RemovedPerformances, perfTitles[perf.ID])
+			}
+		}
+		if row.Action == importUnchanged && (len(row.Answers) > 0 || len(row.AddedPerformances) > 0 || len(row.RemovedPerformances) > 0) {
+			row.Action = importUpdate
+		}
+
+		result = append(result, row)
+	}
+
+	return result, errs
+}
+
+// cellAt は列が足りない行でも空文字を返します
+func cellAt(record []string, col int) string {
+	if col < len(record) {
+		return record[col]
+	}
+	return ""
+}
+
+// isBlankRecord は全ての列が空の行かを返します
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTitles は "演目A、演目B" のように区切られた演目名を分割します
+func splitTitles(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '、' || r == ',' || r == ';' || r == '\n'
+	})
+	titles := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if title := strings.TrimSpace(field); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// openUpload はマルチパートの file フィールド、またはリクエストボディ全体を開きます
+func openUpload(c *gin.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// readImportFile はアップロードされたファイルを CSV として読み込みます
+func readImportFile(c *gin.Context) ([][]string, error) {
+	f, err := openUpload(c)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return export.ReadCSV(f)
+}
+
+// saveImportedRow は1行分の回答を作成または置き換えます
+func saveImportedRow(tx *gorm.DB, eventID string, row importRow) (models.Response, error) {
+	response := models.Response{ID: row.ResponseID, EventID: eventID, Name: row.Name}
+	if row.Action == importCreate {
+		response.CreatedAt = time.Now()
+		if err := tx.Create(&response).Error; err != nil {
+			return response, err
+		}
+	} else {
+		if err := tx.Where("response_id = ?", response.ID).Delete(&models.ResponseAnswer{}).Error; err != nil {
+			return response, err
+		}
+		if err := tx.Where("response_id = ?", response.ID).Delete(&models.UserPerformance{}).Error; err != nil {
+			return response, err
+		}
+	}
+
+	for dateID, status := range row.answers {
+		response.Answers = append(response.Answers, models.ResponseAnswer{
+			ResponseID: response.ID,
+			DateID:     dateID,
+			Status:     status,
+		})
+	}
+	if len(response.Answers) > 0 {
+		if err := tx.Create(&response.Answers).Error; err != nil {
+			return response, err
+		}
+	}
+
+	for _, perfID := range row.performances {
+		response.Performances = append(response.Performances, models.UserPerformance{
+			ResponseID:    response.ID,
+			PerformanceID: perfID,
+		})
+	}
+	if len(response.Performances) > 0 {
+		if err := tx.Create(&response.Performances).Error; err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+// ImportResponses creates or updates responses from a CSV file
+// dry_run=true の場合は差分だけを返し、保存しません
+func (h *Handler) ImportResponses(c *gin.Context) {
+	id := c.Param("id")
+	dryRun := c.Query("dry_run") == "true"
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Preload("Performances").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !checkOpen(c, event) {
+		return
+	}
+
+	records, err := readImportFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV: " + err.Error()})
+		return
+	}
+
+	var existing []models.Response
+	if err := h.db.Preload("Answers").Preload("Performances").Where("event_id = ?", id).Order("id").Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+
+	rows, errs := parseResponseRows(event, existing, records)
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid CSV", "errors": errs})
+		return
+	}
+
+	summary := map[string]int{importCreate: 0, importUpdate: 0, importUnchanged: 0}
+	for _, row := range rows {
+		summary[row.Action]++
+	}
+	if !checkLimit(c, "responses", len(existing)+summary[importCreate], h.cfg.MaxResponses) {
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "rows": rows, "summary": summary})
+		return
+	}
+
+	// 全ての行を1つのトランザクションで保存する
+	// 回答数の上限は、同時に追加された回答も含めてイベントをロックしてから確かめ直す
+	var saved []realtime.Message
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		count, err := lockResponseCount(tx, id)
+		if err != nil {
+			return err
+		}
+		if max := h.cfg.MaxResponses; max > 0 && count+summary[importCreate] > max {
+			return errTooManyResponses
+		}
+		for _, row := range rows {
+			if row.Action == importUnchanged {
+				continue
+			}
+			response, err := saveImportedRow(tx, id, row)
+			if err != nil {
+				return err
+			}
+			msgType := realtime.ResponseUpdated
+			if row.Action == importCreate {
+				msgType = realtime.ResponseCreated
+			}
+			saved = append(saved, realtime.Message{Type: msgType, Data: response})
+		}
+		return nil
+	})
+	if errors.Is(err, errTooManyResponses) {
+		checkLimit(c, "responses", h.cfg.MaxResponses+1, h.cfg.MaxResponses)
+		return
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A response with the same name was added during the import; try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import responses"})
+		return
+	}
+
+	// コミット後に購読中のクライアントへ通知する
+	for _, msg := range saved {
+		h.publish(c.Request.Context(), id, msg)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": false, "rows": rows, "summary": summary})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxSessions は練習回数の上限です
+// セッション展開後のパフォーマンス ID は「元の ID × 100 + 回」のため、100 回以上は扱えません
+const maxSessions = 99
+
+// checkLimit はイベントの日付・パフォーマンス・回答の件数が上限を超えないかを確認します
+// limit が 0 の場合は制限しません
+// 超える場合はエラーレスポンスを書き込み false を返します
+func checkLimit(c *gin.Context, what string, count, limit int) bool {
+	if limit <= 0 || count <= limit {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("an event can have at most %d %s", limit, what)})
+	return false
+}
+
+// checkSessions は最適化の練習回数が上限を超えないかを確認します
+// 超える場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) checkSessions(c *gin.Context, sessions int) bool {
+	if sessions <= h.cfg.MaxSessions {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("sessions must be at most %d", h.cfg.MaxSessions)})
+	return false
+}
+
+// errTooManyResponses と errTooManyDates はトランザクションの中で件数の上限を超えたことを表します
+var (
+	errTooManyResponses = errors.New("too many responses")
+	errTooManyDates     = errors.New("too many dates")
+)
+
+// lockEvent はイベントの行をロックします
+// 同じイベントへの追加はロックを待って順に数えるため、同時に送られても上限を超えません
+func lockEvent(tx *gorm.DB, eventID string) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", eventID).Take(&models.Event{}).Error
+}
+
+// lockResponseCount はイベントの行をロックしてから回答数を数えます
+func lockResponseCount(tx *gorm.DB, eventID string) (int, error) {
+	if err := lockEvent(tx, eventID); err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := tx.Model(&models.Response{}).Where("event_id = ?", eventID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// lockDateCount はイベントの行をロックしてから候補日時の数を数えます
+func lockDateCount(tx *gorm.DB, eventID string) (int, error) {
+	if err := lockEvent(tx, eventID); err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := tx.Model(&models.Date{}).Where("event_id = ?", eventID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
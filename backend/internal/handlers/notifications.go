@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/mail"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/notify"
+)
+
+// eventURL はメールに載せるイベントのページの URL です
+func (h *Handler) eventURL(eventID string) string {
+	return strings.TrimRight(h.cfg.AppURL, "/") + "/events/" + eventID
+}
+
+// recipient はユーザーを通知の宛先に変換します
+func recipient(user models.User) notify.Recipient {
+	return notify.Recipient{Email: user.Email, Name: user.Name, Locale: user.Locale}
+}
+
+// notifyResponseCreated は団体のイベントに回答が届いたことを、通知を希望するメンバーに送ります
+// 回答した本人には送りません
+func (h *Handler) notifyResponseCreated(ctx context.Context, event models.Event, response models.Response) {
+	if event.OrganizationID == nil {
+		return
+	}
+
+	query := h.db.Joins("JOIN memberships ON memberships.user_id = users.id").
+		Where("memberships.organization_id = ? AND users.notify_responses = ?", *event.OrganizationID, true)
+	if response.UserID != nil {
+		query = query.Where("users.id <> ?", *response.UserID)
+	}
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		logging.FromContext(ctx).Error("Failed to load notification recipients", "event_id", event.ID, "error", err)
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Response{}).Where("event_id = ?", event.ID).Count(&count)
+	data := notify.ResponseCreatedData{
+		EventTitle:    event.Title,
+		EventURL:      h.eventURL(event.ID),
+		ResponseName:  response.Name,
+		ResponseCount: count,
+	}
+	for _, user := range users {
+		h.notifier.Notify(ctx, recipient(user), notify.ResponseCreated, data)
+	}
+}
+
+// notifyScheduleConfirmed は確定したスケジュールを、アカウントと結び付いた回答者に .ics 付きで送ります
+// 回答のアカウントは、ログインして回答した場合の UserID か、名簿のメンバーのアカウントです
+func (h *Handler) notifyScheduleConfirmed(ctx context.Context, event models.Event, sessions []models.ScheduledSession) {
+	var responses []models.Response
+	err := h.db.Where("event_id = ? AND (user_id IS NOT NULL OR member_id IS NOT NULL)", event.ID).Order("id").Find(&responses).Error
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load responses for notifications", "event_id", event.ID, "error", err)
+		return
+	}
+
+	var members []models.RosterMember
+	if event.OrganizationID != nil {
+		h.db.Where("organization_id = ? AND user_id IS NOT NULL", *event.OrganizationID).Find(&members)
+	}
+	memberUsers := make(map[uint]uint, len(members))
+	for _, member := range members {
+		memberUsers[member.ID] = *member.UserID
+	}
+
+	// 同じアカウントの回答が複数ある場合は最初の回答だけに送る
+	recipients := make(map[uint]models.Response)
+	var userIDs []uint
+	for _, response := range responses {
+		var userID uint
+		switch {
+		case response.UserID != nil:
+			userID = *response.UserID
+		case response.MemberID != nil && memberUsers[*response.MemberID] != 0:
+			userID = memberUsers[*response.MemberID]
+		default:
+			continue
+		}
+		if _, ok := recipients[userID]; ok {
+			continue
+		}
+		recipients[userID] = response
+		userIDs = append(userIDs, userID)
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+
+	var users []models.User
+	if err := h.db.Where("id IN ? AND notify_schedule = ?", userIDs, true).Find(&users).Error; err != nil {
+		logging.FromContext(ctx).Error("Failed to load notification recipients", "event_id", event.ID, "error", err)
+		return
+	}
+	for _, user := range users {
+		response := recipients[user.ID]
+		joined, err := h.memberSessions(response.ID, sessions)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to load sessions of response", "response_id", response.ID, "error", err)
+			continue
+		}
+
+		data := notify.ScheduleConfirmedData{
+			EventTitle:   event.Title,
+			EventURL:     h.eventURL(event.ID),
+			ResponseName: response.Name,
+		}
+		for _, s := range joined {
+			data.Sessions = append(data.Sessions, notify.Session{When: s.Date.Value, Title: s.Performance.Title})
+		}
+		cal := h.scheduleCalendar(fmt.Sprintf("%s (%s)", event.Title, response.Name), event, joined)
+		h.notifier.Notify(ctx, recipient(user), notify.ScheduleConfirmed, data, mail.Attachment{
+			Filename:    fmt.Sprintf("%s-%d.ics", event.ID, response.ID),
+			ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+			Data:        cal.Encode(),
+		})
+	}
+}
+
+// UpdateNotifications updates the logged-in user's notification preferences
+func (h *Handler) UpdateNotifications(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Locale != nil {
+		updates["locale"] = *req.Locale
+	}
+	if req.NotifyResponses != nil {
+		updates["notify_responses"] = *req.NotifyResponses
+	}
+	if req.NotifySchedule != nil {
+		updates["notify_schedule"] = *req.NotifySchedule
+	}
+	if req.NotifyReminders != nil {
+		updates["notify_reminders"] = *req.NotifyReminders
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification settings"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// optimizationInput は最適化に必要な前処理済みのデータです
+type optimizationInput struct {
+	Event        models.Event
+	SessionCount int                  // 0 の場合はセッション展開なし
+	Performances []models.Performance // 最適化対象のパフォーマンス（セッション展開後）
+	PerfCount    int                  // 最適化対象のパフォーマンス数
+	Users        map[string]*models.UserData
+	Options      []models.ScoredOption // スコアの高い順にソート済み
+}
+
+// loadOptimizationInput はイベントと回答を読み込み、スコア付きの組み合わせを計算します
+// sessionCount が 1 以上ならパフォーマンスを練習回数分に展開します
+// 読み込みに失敗した場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) loadOptimizationInput(c *gin.Context, id string, sessionCount int) (*optimizationInput, bool) {
+	input, err := readOptimizationInput(h.db.WithContext(c.Request.Context()), id, sessionCount)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return nil, false
+	}
+	return input, true
+}
+
+// readOptimizationInput はイベントと回答を読み込み、最適化の入力を組み立てます
+// イベントが存在しない場合は gorm.ErrRecordNotFound を返します
+func readOptimizationInput(db *gorm.DB, id string, sessionCount int) (*optimizationInput, error) {
+	// Get event with dates and performances - 必要なデータのみロード
+	var event models.Event
+	query := db.Preload("Dates").Preload("Performances")
+	if err := query.Where("id = ?", id).First(&event).Error; err != nil {
+		return nil, err
+	}
+
+	// Get all responses with their performance selections and answers
+	var responses []models.Response
+	responseQuery := db.Preload("Answers").Preload("Performances")
+	if err := responseQuery.Where("event_id = ?", id).Find(&responses).Error; err != nil {
+		return nil, err
+	}
+
+	return newOptimizationInput(event, responses, sessionCount), nil
+}
+
+// sessionPerformanceID はセッション展開後のパフォーマンス ID（元の ID × 100 + 回）を返します
+// algorithm.ExpandPerformancesForMultipleSessions と同じ割り当てです
+func sessionPerformanceID(performanceID uint, session int) uint {
+	return performanceID*100 + uint(session)
+}
+
+// sessionNumber はセッション展開後のパフォーマンス ID から練習回を返します
+func sessionNumber(sessionPerformanceID uint) int {
+	return int(sessionPerformanceID % 100)
+}
+
+// newOptimizationInput は読み込み済みのイベントと回答から最適化の入力を組み立てます
+func newOptimizationInput(event models.Event, responses []models.Response, sessionCount int) *optimizationInput {
+	perfs := event.Performances
+	if sessionCount > 0 {
+		// 練習回数分にパフォーマンスを拡張
+		perfs = algorithm.ExpandPerformancesForMultipleSessions(event.Performances, sessionCount)
+	}
+
+	// データ前処理: パフォーマンス参加と日付可用性のマップを構築
+	// この前処理により、後のルックアップが O(1) 時間で行える
+	users := make(map[string]*models.UserData, len(responses))
+	keys := responseKeys(responses)
+	for _, response := range responses {
+		userData := &models.UserData{
+			Name:         keys[response.ID],
+			Performances: make(map[uint]bool, len(response.Performances)),
+			Availability: make(map[uint]models.AnswerStatus, len(response.Answers)),
+		}
+
+		// パフォーマンス参加情報をマップに格納
+		for _, perf := range response.Performances {
+			if sessionCount == 0 {
+				userData.Performances[perf.PerformanceID] = true
+				continue
+			}
+			// オリジナルのパフォーマンス参加情報を全練習セッションに適用
+			for i := 1; i <= sessionCount; i++ {
+				userData.Performances[sessionPerformanceID(perf.PerformanceID, i)] = true
+			}
+		}
+
+		// 可用性情報をマップに格納
+		for _, answer := range response.Answers {
+			userData.Availability[answer.DateID] = answer.Status
+		}
+
+		users[keys[response.ID]] = userData
+	}
+
+	return &optimizationInput{
+		Event:        event,
+		SessionCount: sessionCount,
+		Performances: perfs,
+		PerfCount:    len(perfs),
+		Users:        users,
+		Options:      scoreOptions(perfs, event.Dates, users),
+	}
+}
+
+// responseKeys は回答ごとにメンバーを区別するキー（表示名）を返します
+// 同じ名前の回答が複数ある場合、2件目以降は "名前 (#回答ID)" として別のメンバーとして扱います
+func responseKeys(responses []models.Response) map[uint]string {
+	keys := make(map[uint]string, len(responses))
+	used := make(map[string]bool, len(responses))
+	for _, response := range responses {
+		key := response.Name
+		if used[key] {
+			key = fmt.Sprintf("%s (#%d)", response.Name, response.ID)
+		}
+		used[key] = true
+		keys[response.ID] = key
+	}
+	return keys
+}
+
+// scoreOptions は全ての日付×パフォーマンス組み合わせのスコアを一度に計算し、
+// スコアの高い順にソートして返します
+func scoreOptions(perfs []models.Performance, dates []models.Date, users map[string]*models.UserData) []models.ScoredOption {
+	perfCount := len(perfs)
+	dateCount := len(dates)
+
+	// 二次元配列を使用して、頻繁なメモリアロケーションを避ける
+	type ScoreData struct {
+		AvailableCount   int
+		MaybeCount       int
+		UnavailableCount int
+		UnansweredCount  int
+		TotalCount       int
+		ConflictCount    int
+		WeightedScore    float64
+		ConflictingUsers []string
+	}
+
+	// スコアデータの二次元配列を初期化
+	scores := make([][]ScoreData, perfCount)
+	for i := range scores {
+		scores[i] = make([]ScoreData, dateCount)
+	}
+
+	// パフォーマンスと日付のマッピング用インデックス
+	perfIndex := make(map[uint]int, perfCount)
+	dateIndex := make(map[uint]int, dateCount)
+
+	for i, p := range perfs {
+		perfIndex[p.ID] = i
+	}
+
+	for i, d := range dates {
+		dateIndex[d.ID] = i
+	}
+
+	// すべてのユーザーについて一度だけ処理することで、O(n³)からO(n²)に計算量を削減
+	for _, userData := range users {
+		// このユーザーが参加するパフォーマンスについて
+		userPerfs := make([]uint, 0, len(userData.Performances))
+		for perfID := range userData.Performances {
+			userPerfs = append(userPerfs, perfID)
+		}
+
+		hasMultiplePerfs := len(userPerfs) > 1
+
+		// このユーザーの各パフォーマンスと各日付の組み合わせをチェック
+		for _, perfID := range userPerfs {
+			pIdx, ok := perfIndex[perfID]
+			if !ok {
+				continue // 無効なパフォーマンスIDはスキップ
+			}
+
+			// このユーザーの各日付での可用性をチェック（回答がない日付は未回答）
+			for dIdx, date := range dates {
+				status := userData.Availability[date.ID]
+
+				scoreData := &scores[pIdx][dIdx]
+				scoreData.TotalCount++
+
+				// 可用性に応じてカウントとスコアを更新
+				switch status {
+				case models.StatusAvailable:
+					scoreData.AvailableCount++
+					scoreData.WeightedScore += 1.0
+				case models.StatusMaybe:
+					scoreData.MaybeCount++
+					scoreData.WeightedScore += 0.5
+				case models.StatusUnavailable:
+					scoreData.UnavailableCount++
+				default:
+					// 未回答・回答なしは参加不可と区別し、扱いは最適化の設定で決める
+					scoreData.UnansweredCount++
+				}
+
+				// 複数パフォーマンスに参加する場合は潜在的コンフリクト
+				if hasMultiplePerfs {
+					scoreData.ConflictCount++
+					if !containsString(scoreData.ConflictingUsers, userData.Name) {
+						scoreData.ConflictingUsers = append(scoreData.ConflictingUsers, userData.Name)
+					}
+				}
+			}
+		}
+	}
+
+	allOptions := make([]models.ScoredOption, 0, perfCount*dateCount)
+
+	// 全ての組み合わせをフラットなリストに変換
+	for pIdx, perfScores := range scores {
+		perfID := perfs[pIdx].ID
+		perfName := perfs[pIdx].Title
+
+		for dIdx, score := range perfScores {
+			dateID := dates[dIdx].ID
+			dateValue := dates[dIdx].Value
+
+			option := models.ScoredOption{
+				PerformanceID:    perfID,
+				DateID:           dateID,
+				PerformanceName:  perfName,
+				DateValue:        dateValue,
+				AvailableCount:   score.AvailableCount,
+				MaybeCount:       score.MaybeCount,
+				UnavailableCount: score.UnavailableCount,
+				UnansweredCount:  score.UnansweredCount,
+				TotalCount:       score.TotalCount,
+				ConflictCount:    score.ConflictCount,
+				WeightedScore:    score.WeightedScore,
+				ConflictingUsers: score.ConflictingUsers,
+			}
+
+			allOptions = append(allOptions, option)
+		}
+	}
+
+	// スコアの高い順にソート - クイックソートを利用
+	sort.Slice(allOptions, func(i, j int) bool {
+		// 主要ソート基準: 重み付きスコア (高いほど良い)
+		if allOptions[i].WeightedScore != allOptions[j].WeightedScore {
+			return allOptions[i].WeightedScore > allOptions[j].WeightedScore
+		}
+
+		// 二次ソート基準: コンフリクト数 (少ないほど良い)
+		if allOptions[i].ConflictCount != allOptions[j].ConflictCount {
+			return allOptions[i].ConflictCount < allOptions[j].ConflictCount
+		}
+
+		// 三次ソート基準: available人数 (多いほど良い)
+		if allOptions[i].AvailableCount != allOptions[j].AvailableCount {
+			return allOptions[i].AvailableCount > allOptions[j].AvailableCount
+		}
+
+		// 四次ソート基準: maybe人数 (多いほど良い)
+		return allOptions[i].MaybeCount > allOptions[j].MaybeCount
+	})
+
+	return allOptions
+}
+
+// optimizerOptions は "relocate:4,swap:3,session_shift:1,chain:2" 形式の手の配分と
+// 未回答の扱い（optimistic / pessimistic / maybe）から焼きなまし法のパラメータを組み立てます
+func optimizerOptions(moves, unanswered string) (algorithm.Options, error) {
+	opts := algorithm.DefaultOptions()
+	if moves != "" {
+		mix, err := algorithm.ParseMoveMix(moves)
+		if err != nil {
+			return opts, err
+		}
+		opts.MoveMix = mix
+	}
+	if unanswered != "" {
+		policy, err := algorithm.ParseUnansweredPolicy(unanswered)
+		if err != nil {
+			return opts, err
+		}
+		opts.Unanswered = policy
+	}
+	return opts, nil
+}
+
+// optimizeContext はリクエストのコンテキストに最適化の期限を設定します
+// timeout クエリパラメータ（例: "5s"）でサーバー設定より短い期限を指定できます
+func (h *Handler) optimizeContext(c *gin.Context) (context.Context, context.CancelFunc, error) {
+	timeout := h.cfg.OptimizeTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		requested, err := time.ParseDuration(raw)
+		if err != nil || requested <= 0 {
+			return nil, nil, fmt.Errorf("invalid timeout %q", raw)
+		}
+		if timeout == 0 || requested < timeout {
+			timeout = requested
+		}
+	}
+
+	if timeout == 0 {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// logOptimization は最適化の実行結果を、入力の大きさ・エネルギー・所要時間とともにログに出力します
+func logOptimization(ctx context.Context, input *optimizationInput, result algorithm.Result, elapsedTime time.Duration) {
+	logging.FromContext(ctx).Info("optimization finished",
+		"event_id", input.Event.ID,
+		"performances", input.PerfCount,
+		"dates", len(input.Event.Dates),
+		"respondents", len(input.Users),
+		"options", len(input.Options),
+		"sessions", input.SessionCount,
+		"energy", result.Energy,
+		"iterations", result.Iterations,
+		"scheduled", len(result.Schedule),
+		"truncated", result.Truncated,
+		"duration", elapsedTime,
+	)
+}
+
+// optimizationResponse は最適化結果をレスポンス形式に整形します
+func optimizationResponse(result algorithm.Result, perfCount int, elapsedTime time.Duration) gin.H {
+	// 全体のスコアと統計を計算
+	var totalWeightedScore float64
+	var totalConflicts int
+	var totalAvailable int
+	var totalMaybe int
+	var totalUnavailable int
+	var totalUnanswered int
+
+	for _, opt := range result.Schedule {
+		totalWeightedScore += opt.WeightedScore
+		totalConflicts += opt.ConflictCount
+		totalAvailable += opt.AvailableCount
+		totalMaybe += opt.MaybeCount
+		totalUnavailable += opt.UnavailableCount
+		totalUnanswered += opt.UnansweredCount
+	}
+
+	return gin.H{
+		"suggested_schedule": result.Schedule,
+		"metrics": gin.H{
+			"total_weighted_score":   totalWeightedScore,
+			"total_conflicts":        totalConflicts,
+			"total_available":        totalAvailable,
+			"total_maybe":            totalMaybe,
+			"total_unavailable":      totalUnavailable,
+			"total_unanswered":       totalUnanswered,
+			"performance_count":      perfCount,
+			"scheduled_performances": len(result.Schedule),
+			"computation_time_ms":    float64(elapsedTime.Microseconds()) / 1000.0,
+			"iterations":             result.Iterations,
+			"move_stats":             result.MoveStats,
+			"truncated":              result.Truncated,
+		},
+	}
+}
+
+// StreamOptimalSchedule は最適化の途中経過を Server-Sent Events で配信し、最後に結果を送ります
+// イベント: "progress"（温度・現在のエネルギー・最良エネルギー）、"result"（最適化結果）
+func (h *Handler) StreamOptimalSchedule(c *gin.Context) {
+	id := c.Param("id")
+	startTime := time.Now()
+
+	// セッション数（省略時はセッション展開なし）
+	sessionCount, err := strconv.Atoi(c.DefaultQuery("sessions", "0"))
+	if err != nil || sessionCount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sessions"})
+		return
+	}
+	if !h.checkSessions(c, sessionCount) {
+		return
+	}
+
+	opts, err := optimizerOptions(c.Query("moves"), c.Query("unanswered"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel, err := h.optimizeContext(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer cancel()
+
+	input, ok := h.loadOptimizationInput(c, id, sessionCount)
+	if !ok {
+		return
+	}
+
+	// 途中経過はクライアントが遅い場合に取りこぼしてもよい
+	progressCh := make(chan algorithm.Progress, 16)
+	resultCh := make(chan algorithm.Result, 1)
+	opts.Progress = func(p algorithm.Progress) {
+		select {
+		case progressCh <- p:
+		default:
+		}
+	}
+
+	go func() {
+		resultCh <- algorithm.Optimize(ctx, input.Options, input.PerfCount, input.Users, opts)
+	}()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// クライアントが切断すると c.Stream が終了し、ctx のキャンセルで最適化も止まる
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case p := <-progressCh:
+			c.SSEvent("progress", p)
+			return true
+		case result := <-resultCh:
+			// 結果より前の途中経過を送り切る
+		drain:
+			for {
+				select {
+				case p := <-progressCh:
+					c.SSEvent("progress", p)
+				default:
+					break drain
+				}
+			}
+			elapsedTime := time.Since(startTime)
+			logOptimization(ctx, input, result, elapsedTime)
+			c.SSEvent("result", optimizationResponse(result, input.PerfCount, elapsedTime))
+			return false
+		}
+	})
+}
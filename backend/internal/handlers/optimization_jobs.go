@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/jobs"
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+// optimizationJobResponse はジョブの状態と、終了していればその結果を表します
+type optimizationJobResponse struct {
+	models.OptimizationJob
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// optimizationJob は input を opts で最適化するジョブの処理を返します
+// ジョブはリクエストの後も動き続けるため、リクエストIDなどはロガーとして引き継ぎます
+func optimizationJob(logger *slog.Logger, input *optimizationInput, opts algorithm.Options) jobs.RunFunc {
+	return func(ctx context.Context, progress func(algorithm.Progress)) (interface{}, error) {
+		ctx = logging.WithLogger(ctx, logger)
+		startTime := time.Now()
+		opts.Progress = progress
+
+		result := algorithm.Optimize(ctx, input.Options, input.PerfCount, input.Users, opts)
+		elapsedTime := time.Since(startTime)
+		logOptimization(ctx, input, result, elapsedTime)
+		return optimizationResponse(result, input.PerfCount, elapsedTime), nil
+	}
+}
+
+// CreateOptimization はバックグラウンドで最適化ジョブを開始します
+func (h *Handler) CreateOptimization(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.CreateOptimizationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Sessions < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sessions must not be negative"})
+		return
+	}
+	if !h.checkSessions(c, req.Sessions) {
+		return
+	}
+
+	opts, err := optimizerOptions(req.Moves, req.Unanswered)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 入力データはリクエスト中に読み込み、存在しないイベントはすぐに 404 を返す
+	input, ok := h.loadOptimizationInput(c, id, req.Sessions)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobs.Submit(id, req.Sessions, optimizationJob(logging.FromContext(c.Request.Context()), input, opts))
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many optimizations in progress"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create optimization"})
+		return
+	}
+
+	c.Header("Location", "/api/events/"+id+"/optimizations/"+job.ID)
+	c.JSON(http.StatusAccepted, optimizationJobResponse{OptimizationJob: job})
+}
+
+// GetOptimization は最適化ジョブの状態・進捗と、終了していれば結果を返します
+func (h *Handler) GetOptimization(c *gin.Context) {
+	id := c.Param("id")
+	jobID := c.Param("jobId")
+
+	job, result, err := h.jobs.Get(id, jobID)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Optimization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get optimization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, optimizationJobResponse{OptimizationJob: job, Result: result})
+}
+
+// CancelOptimization は待機中・実行中の最適化ジョブをキャンセルします
+func (h *Handler) CancelOptimization(c *gin.Context) {
+	id := c.Param("id")
+	jobID := c.Param("jobId")
+
+	job, err := h.jobs.Cancel(id, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Optimization not found"})
+		case errors.Is(err, jobs.ErrFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": "Optimization already finished"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel optimization"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, optimizationJobResponse{OptimizationJob: job})
+}
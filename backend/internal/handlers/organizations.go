@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// errLastOwner は団体の最後のオーナーを外そうとしたことを表します
+var errLastOwner = errors.New("an organization needs at least one owner")
+
+// isMember は userID が団体のメンバーかを返します
+func (h *Handler) isMember(orgID, userID uint) bool {
+	var count int64
+	h.db.Model(&models.Membership{}).Where("organization_id = ? AND user_id = ?", orgID, userID).Count(&count)
+	return count > 0
+}
+
+// requireMember はログインしているユーザーの :orgId の団体での所属を取得します
+// role に RoleOwner を指定した場合はオーナーだけを許可します
+// 所属していない団体は存在を明かさないよう 404 を返し、権限が足りない場合は 403 を返します
+func (h *Handler) requireMember(c *gin.Context, role string) (models.Membership, bool) {
+	var membership models.Membership
+	user, ok := h.requireUser(c)
+	if !ok {
+		return membership, false
+	}
+
+	orgID, err := strconv.ParseUint(c.Param("orgId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return membership, false
+	}
+	if err := h.db.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&membership).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return membership, false
+	}
+	if role == models.RoleOwner && membership.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can do this"})
+		return membership, false
+	}
+	return membership, true
+}
+
+// CreateOrganization creates an organization owned by the logged-in user
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	org := models.Organization{Name: name, CreatedAt: time.Now()}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		owner := models.Membership{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           models.RoleOwner,
+			CreatedAt:      time.Now(),
+		}
+		return tx.Omit("User").Create(&owner).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// GetOrganizations lists the organizations the logged-in user belongs to
+func (h *Handler) GetOrganizations(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var orgs []models.Organization
+	err := h.db.Where("id IN (?)", h.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", user.ID)).
+		Order("name").Find(&orgs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+// GetOrganization retrieves an organization with its members
+func (h *Handler) GetOrganization(c *gin.Context) {
+	membership, ok := h.requireMember(c, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	var org models.Organization
+	if err := h.db.Preload("Members.User").First(&org, membership.OrganizationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// AddMember adds a user to an organization by email address
+// アカウントがまだない場合は作成し、本人はマジックリンクでそのままログインできます
+func (h *Handler) AddMember(c *gin.Context) {
+	owner, ok := h.requireMember(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := req.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	var membership models.Membership
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Where(models.User{Email: normalizeEmail(req.Email)}).
+			Attrs(models.User{Name: strings.TrimSpace(req.Name), CreatedAt: time.Now()}).
+			FirstOrCreate(&user).Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Membership{}).Where("organization_id = ? AND user_id = ?", owner.OrganizationID, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+
+		membership = models.Membership{
+			OrganizationID: owner.OrganizationID,
+			UserID:         user.ID,
+			User:           user,
+			Role:           role,
+			CreatedAt:      time.Now(),
+		}
+		return tx.Omit("User").Create(&membership).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, membership)
+}
+
+// RemoveMember removes a user from an organization
+// 自分自身は誰でも外せますが、ほかのメンバーを外せるのはオーナーだけです
+func (h *Handler) RemoveMember(c *gin.Context) {
+	self, ok := h.requireMember(c, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if uint(userID) != self.UserID && self.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can do this"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.Where("organization_id = ? AND user_id = ?", self.OrganizationID, userID).First(&membership).Error; err != nil {
+			return err
+		}
+		if membership.Role == models.RoleOwner {
+			var owners int64
+			if err := tx.Model(&models.Membership{}).Where("organization_id = ? AND role = ?", self.OrganizationID, models.RoleOwner).Count(&owners).Error; err != nil {
+				return err
+			}
+			if owners <= 1 {
+				return errLastOwner
+			}
+		}
+		return tx.Delete(&membership).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	case errors.Is(err, errLastOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// GetOrganizationEvents lists the events owned by an organization
+func (h *Handler) GetOrganizationEvents(c *gin.Context) {
+	membership, ok := h.requireMember(c, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	var events []models.Event
+	if err := h.db.Where("organization_id = ?", membership.OrganizationID).Order("created_at DESC").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
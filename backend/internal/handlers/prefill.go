@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/ical"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+	"gorm.io/gorm"
+)
+
+// proposeAnswers はメンバーの予定と各候補日時を突き合わせて回答案を作ります
+// 予定と重なる日時は unavailable、仮の予定と重なる日時は maybe、それ以外は available です
+// 解釈できない Date.Value の日付は回答案に含めません
+func proposeAnswers(dates []models.Date, fb ical.FreeBusy, loc *time.Location) map[uint]models.AnswerStatus {
+	busy := timeslot.Merge(toSlots(fb.Busy))
+	tentative := timeslot.Merge(toSlots(fb.Tentative))
+
+	answers := make(map[uint]models.AnswerStatus, len(dates))
+	for _, date := range dates {
+		slot, err := timeslot.Parse(date.Value, loc)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case overlapsAny(slot, busy):
+			answers[date.ID] = models.StatusUnavailable
+		case overlapsAny(slot, tentative):
+			answers[date.ID] = models.StatusMaybe
+		default:
+			answers[date.ID] = models.StatusAvailable
+		}
+	}
+	return answers
+}
+
+// overlapsAny は slot がいずれかの時間帯と重なるかを返します
+func overlapsAny(slot timeslot.Slot, periods []timeslot.Slot) bool {
+	for _, p := range periods {
+		if slot.Overlaps(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// datesWindow は候補日時全体を含む期間を返します（解釈できる日時がなければゼロ値）
+// メンバーの予定の繰り返しはこの期間の分だけ展開します
+func datesWindow(dates []models.Date, loc *time.Location) ical.Period {
+	var window ical.Period
+	for _, date := range dates {
+		slot, err := timeslot.Parse(date.Value, loc)
+		if err != nil {
+			continue
+		}
+		if window.Start.IsZero() || slot.Start.Before(window.Start) {
+			window.Start = slot.Start
+		}
+		if slot.End.After(window.End) {
+			window.End = slot.End
+		}
+	}
+	return window
+}
+
+// readMemberCalendar はアップロードされたメンバーの .ics を、候補日時の期間について読み込みます
+// 失敗した場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) readMemberCalendar(c *gin.Context, dates []models.Date) (ical.FreeBusy, bool) {
+	f, err := openUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return ical.FreeBusy{}, false
+	}
+	defer f.Close()
+
+	fb, err := ical.ParseFreeBusy(f, h.location(), datesWindow(dates, h.location()))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid iCalendar: " + err.Error()})
+		return ical.FreeBusy{}, false
+	}
+	return fb, true
+}
+
+// PrefillAnswers proposes answers from a member's calendar in the shape of CreateResponseRequest
+// 保存はしないので、フォームで確認してから AddResponse に送ります
+func (h *Handler) PrefillAnswers(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	fb, ok := h.readMemberCalendar(c, event.Dates)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CreateResponseRequest{
+		Name:         c.Query("name"),
+		Answers:      proposeAnswers(event.Dates, fb, h.location()),
+		Performances: []uint{},
+	})
+}
+
+// PrefillResponseAnswers stores the answers proposed from a member's calendar into an existing response
+// カレンダーから判断できた日付の回答だけを置き換え、参加演目は変更しません
+func (h *Handler) PrefillResponseAnswers(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	response, ok := h.findResponse(c, id)
+	if !ok {
+		return
+	}
+
+	fb, ok := h.readMemberCalendar(c, event.Dates)
+	if !ok {
+		return
+	}
+
+	proposed := proposeAnswers(event.Dates, fb, h.location())
+	dateIDs := make([]uint, 0, len(proposed))
+	answers := make([]models.ResponseAnswer, 0, len(proposed))
+	for _, date := range event.Dates {
+		status, ok := proposed[date.ID]
+		if !ok {
+			continue
+		}
+		dateIDs = append(dateIDs, date.ID)
+		answers = append(answers, models.ResponseAnswer{
+			ResponseID: response.ID,
+			DateID:     date.ID,
+			Status:     status,
+		})
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if len(answers) == 0 {
+			return nil
+		}
+		if err := tx.Where("response_id = ? AND date_id IN ?", response.ID, dateIDs).Delete(&models.ResponseAnswer{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&answers).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answers"})
+		return
+	}
+
+	if err := h.db.Preload("Answers").Preload("Performances").First(&response, response.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get response"})
+		return
+	}
+	h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseUpdated, Data: response})
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/ical"
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+func TestProposeAnswersWithRecurringEvents(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 繰り返しの予定はいずれも候補日より前の4月に始まる
+	dates := []models.Date{
+		{ID: 1, Value: "2025-05-20 19:00-21:00"}, // 火曜のアルバイト
+		{ID: 2, Value: "2025-05-22 19:00-21:00"}, // 木曜だが EXDATE で休み
+		{ID: 3, Value: "2025-05-26 18:00-20:00"}, // 月曜の仮のゼミと重なる
+		{ID: 4, Value: "2025-05-27 10:00-12:00"}, // 火曜の午前は空いている
+		{ID: 5, Value: "2025-06-05 20:00-22:00"}, // 木曜のアルバイト
+		{ID: 6, Value: "2025-07-07 18:00-20:00"}, // ゼミの UNTIL より後
+	}
+
+	f, err := os.Open("testdata/recurring_busy.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fb, err := ical.ParseFreeBusy(f, tokyo, datesWindow(dates, tokyo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]models.AnswerStatus{
+		1: models.StatusUnavailable,
+		2: models.StatusAvailable,
+		3: models.StatusMaybe,
+		4: models.StatusAvailable,
+		5: models.StatusUnavailable,
+		6: models.StatusAvailable,
+	}
+	got := proposeAnswers(dates, fb, tokyo)
+	for id, status := range want {
+		if got[id] != status {
+			t.Errorf("date %d (%s): got %q, want %q", id, dates[id-1].Value, got[id], status)
+		}
+	}
+}
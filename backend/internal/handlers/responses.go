@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// findResponse はイベントに属する回答を取得します
+// 見つからない場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) findResponse(c *gin.Context, eventID string) (models.Response, bool) {
+	var response models.Response
+	responseID := c.Param("responseId")
+	if err := h.db.Where("id = ? AND event_id = ?", responseID, eventID).First(&response).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Response not found"})
+		return response, false
+	}
+	return response, true
+}
+
+// findEvent は :id のイベントを取得します（整理する権限は RequireOrganizer で確認済み）
+// 見つからない場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) findEvent(c *gin.Context) (models.Event, bool) {
+	var event models.Event
+	if err := h.db.Where("id = ?", c.Param("id")).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return event, false
+	}
+	return event, true
+}
+
+// checkDuplicateName は同じイベントに正規化した名前が同じ回答がないかを確認します
+// responseID の回答（更新中の回答）は除きます
+// 重複している場合は既存の回答の ID を付けて 409 を書き込み false を返します
+// 同時に送られた回答は一意制約で弾かれるため、保存に失敗したときは respondDuplicateName を使います
+func (h *Handler) checkDuplicateName(c *gin.Context, eventID, name string, responseID uint) bool {
+	var responses []models.Response
+	err := h.db.Select("id", "name").
+		Where("event_id = ? AND normalized_name = ? AND id <> ?", eventID, models.NormalizeName(name), responseID).
+		Limit(1).Find(&responses).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check responses"})
+		return false
+	}
+	if len(responses) == 0 {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       fmt.Sprintf("%q has already responded to this event", responses[0].Name),
+		"response_id": responses[0].ID,
+	})
+	return false
+}
+
+// respondDuplicateName は名前の一意制約に違反して保存できなかった回答の 409 を書き込みます
+func (h *Handler) respondDuplicateName(c *gin.Context, eventID, name string, responseID uint) {
+	if h.checkDuplicateName(c, eventID, name, responseID) {
+		// 重なっていた回答がその後に削除された場合
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q has already responded to this event", name)})
+	}
+}
+
+// validateAnswers は回答の状態が既知の値かを確認します
+func validateAnswers(answers map[uint]models.AnswerStatus) error {
+	for dateID, status := range answers {
+		if !status.Valid() {
+			return fmt.Errorf("invalid status %q for date %d", status, dateID)
+		}
+	}
+	return nil
+}
+
+// UpdateResponse replaces the name, answers and performance selections of a response
+func (h *Handler) UpdateResponse(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !checkOpen(c, event) {
+		return
+	}
+
+	response, ok := h.findResponse(c, id)
+	if !ok {
+		return
+	}
+
+	var req models.CreateResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateAnswers(req.Answers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, memberID, ok := h.responseIdentity(c, event, req, response.ID)
+	if !ok {
+		return
+	}
+
+	// Start transaction
+	tx := h.db.Begin()
+	// 名簿のメンバーの回答はイベントをロックしてから確かめ直し、同時に送られた回答と重ならないようにする
+	if memberID != nil {
+		if err := lockEvent(tx, id); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check responses"})
+			return
+		}
+		if !checkMemberResponse(c, tx, id, *memberID, response.ID) {
+			tx.Rollback()
+			return
+		}
+	}
+	newlyLinked := memberID != nil && (response.MemberID == nil || *response.MemberID != *memberID)
+	response.SetName(name)
+	response.MemberID = memberID
+	if err := tx.Model(&response).Select("name", "normalized_name", "member_id").Updates(&response).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			h.respondDuplicateName(c, id, name, response.ID)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update response"})
+		return
+	}
+
+	// 既存の回答とパフォーマンス選択を置き換える
+	if err := tx.Where("response_id = ?", response.ID).Delete(&models.ResponseAnswer{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answers"})
+		return
+	}
+	if err := tx.Where("response_id = ?", response.ID).Delete(&models.UserPerformance{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user performances"})
+		return
+	}
+
+	var answers []models.ResponseAnswer
+	for dateID, status := range req.Answers {
+		answers = append(answers, models.ResponseAnswer{
+			ResponseID: response.ID,
+			DateID:     dateID,
+			Status:     status,
+		})
+	}
+	if len(answers) > 0 {
+		if err := tx.Create(&answers).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answers"})
+			return
+		}
+	}
+
+	var userPerformances []models.UserPerformance
+	for _, perfID := range req.Performances {
+		userPerformances = append(userPerformances, models.UserPerformance{
+			ResponseID:    response.ID,
+			PerformanceID: perfID,
+		})
+	}
+	if len(userPerformances) > 0 {
+		if err := tx.Create(&userPerformances).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user performances"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update response"})
+		return
+	}
+
+	response.Answers = answers
+	response.Performances = userPerformances
+	h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseUpdated, Data: response})
+	if newlyLinked {
+		h.publishRosterCompleted(c.Request.Context(), event)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteResponse deletes a response with its answers and performance selections
+func (h *Handler) DeleteResponse(c *gin.Context) {
+	id := c.Param("id")
+
+	response, ok := h.findResponse(c, id)
+	if !ok {
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Where("response_id = ?", response.ID).Delete(&models.ResponseAnswer{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete answers"})
+		return
+	}
+	if err := tx.Where("response_id = ?", response.ID).Delete(&models.UserPerformance{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user performances"})
+		return
+	}
+	if err := tx.Delete(&response).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete response"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete response"})
+		return
+	}
+
+	h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseDeleted, Data: gin.H{"id": response.ID, "name": response.Name}})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Response deleted successfully"})
+}
+
+// requireOrganizer は団体のイベントでは団体のメンバーであることを確認します
+// 匿名のイベントは閲覧できる人なら誰でも整理できます
+// 権限がない場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) requireOrganizer(c *gin.Context, event models.Event) bool {
+	if event.OrganizationID == nil {
+		return true
+	}
+	user, ok := h.requireUser(c)
+	if !ok {
+		return false
+	}
+	if !h.isMember(*event.OrganizationID, user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization members can do this"})
+		return false
+	}
+	return true
+}
+
+// GetDuplicateResponses lists groups of responses whose names are the same after normalization
+func (h *Handler) GetDuplicateResponses(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var responses []models.Response
+	if err := h.db.Select("id", "name").Where("event_id = ?", id).Order("id").Find(&responses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+
+	type duplicate struct {
+		Name        string   `json:"name"`
+		ResponseIDs []uint   `json:"response_ids"`
+		Names       []string `json:"names"`
+	}
+	groups := make(map[string]*duplicate)
+	var order []string
+	for _, response := range responses {
+		key := models.NormalizeName(response.Name)
+		group, ok := groups[key]
+		if !ok {
+			group = &duplicate{Name: response.Name}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.ResponseIDs = append(group.ResponseIDs, response.ID)
+		group.Names = append(group.Names, response.Name)
+	}
+
+	duplicates := make([]duplicate, 0)
+	for _, key := range order {
+		if len(groups[key].ResponseIDs) > 1 {
+			duplicates = append(duplicates, *groups[key])
+		}
+	}
+
+	c.JSON(http.StatusOK, duplicates)
+}
+
+// errIdentityMismatch は別のメンバー・アカウントの回答を統合しようとしたことを表します
+var errIdentityMismatch = errors.New("responses belong to different members")
+
+// mergeResponse は sources の回答を target に統合します
+// 日付ごとに target の回答を優先し、target が未回答の日付は sources の最初の回答を使います
+// 参加演目は和集合にし、名簿のメンバーとアカウントは target になければ sources から引き継ぎます
+func mergeResponse(target *models.Response, sources []models.Response) error {
+	answers := make(map[uint]models.AnswerStatus, len(target.Answers))
+	for _, answer := range target.Answers {
+		answers[answer.DateID] = answer.Status
+	}
+	perfs := make(map[uint]bool, len(target.Performances))
+	for _, up := range target.Performances {
+		perfs[up.PerformanceID] = true
+	}
+
+	for _, source := range sources {
+		if source.MemberID != nil {
+			if target.MemberID != nil && *target.MemberID != *source.MemberID {
+				return errIdentityMismatch
+			}
+			target.MemberID = source.MemberID
+		}
+		if source.UserID != nil {
+			if target.UserID != nil && *target.UserID != *source.UserID {
+				return errIdentityMismatch
+			}
+			target.UserID = source.UserID
+		}
+
+		for _, answer := range source.Answers {
+			if status, ok := answers[answer.DateID]; !ok || status == models.StatusUnanswered {
+				answers[answer.DateID] = answer.Status
+			}
+		}
+		for _, up := range source.Performances {
+			perfs[up.PerformanceID] = true
+		}
+	}
+
+	target.Answers = make([]models.ResponseAnswer, 0, len(answers))
+	for dateID, status := range answers {
+		target.Answers = append(target.Answers, models.ResponseAnswer{ResponseID: target.ID, DateID: dateID, Status: status})
+	}
+	sort.Slice(target.Answers, func(i, j int) bool { return target.Answers[i].DateID < target.Answers[j].DateID })
+	target.Performances = make([]models.UserPerformance, 0, len(perfs))
+	for perfID := range perfs {
+		target.Performances = append(target.Performances, models.UserPerformance{ResponseID: target.ID, PerformanceID: perfID})
+	}
+	sort.Slice(target.Performances, func(i, j int) bool {
+		return target.Performances[i].PerformanceID < target.Performances[j].PerformanceID
+	})
+	return nil
+}
+
+// MergeResponses merges duplicate responses into the :responseId response and deletes them
+func (h *Handler) MergeResponses(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	target, ok := h.findResponse(c, id)
+	if !ok {
+		return
+	}
+
+	var req models.MergeResponsesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sourceIDs := make([]uint, 0, len(req.ResponseIDs))
+	seen := map[uint]bool{target.ID: true}
+	for _, sourceID := range req.ResponseIDs {
+		if !seen[sourceID] {
+			seen[sourceID] = true
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "response_ids must contain other responses"})
+		return
+	}
+
+	if err := h.db.Preload("Answers").Preload("Performances").First(&target, target.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get response"})
+		return
+	}
+	var sources []models.Response
+	if err := h.db.Preload("Answers").Preload("Performances").Where("event_id = ? AND id IN ?", id, sourceIDs).Find(&sources).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+	if len(sources) != len(sourceIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Response not found"})
+		return
+	}
+	// リクエストの順に統合する（先に指定した回答を優先）
+	position := make(map[uint]int, len(sourceIDs))
+	for i, sourceID := range sourceIDs {
+		position[sourceID] = i
+	}
+	sort.Slice(sources, func(i, j int) bool { return position[sources[i].ID] < position[sources[j].ID] })
+
+	if err := mergeResponse(&target, sources); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		ids := append([]uint{target.ID}, sourceIDs...)
+		if err := tx.Where("response_id IN ?", ids).Delete(&models.ResponseAnswer{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("response_id IN ?", ids).Delete(&models.UserPerformance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", sourceIDs).Delete(&models.Response{}).Error; err != nil {
+			return err
+		}
+		// 一意にする前からの重複を統合した場合は、残る回答に正規化した名前を設定する
+		target.SetName(target.Name)
+		if err := tx.Model(&target).Select("normalized_name", "member_id", "user_id").Updates(&target).Error; err != nil {
+			return err
+		}
+		if len(target.Answers) > 0 {
+			if err := tx.Create(&target.Answers).Error; err != nil {
+				return err
+			}
+		}
+		if len(target.Performances) > 0 {
+			if err := tx.Create(&target.Performances).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 同じ名前の回答がほかにも残っている
+		h.respondDuplicateName(c, id, target.Name, target.ID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge responses"})
+		return
+	}
+
+	for _, source := range sources {
+		h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseDeleted, Data: gin.H{"id": source.ID, "name": source.Name}})
+	}
+	h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ResponseUpdated, Data: target})
+
+	c.JSON(http.StatusOK, target)
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// memberNames は名簿のメンバーの表示名と別名を返します
+func memberNames(member models.RosterMember) []string {
+	names := []string{member.DisplayName}
+	for _, alias := range member.Aliases {
+		names = append(names, alias.Alias)
+	}
+	return names
+}
+
+// rosterIndex は正規化した名前から名簿のメンバーを引く索引です
+type rosterIndex map[string]*models.RosterMember
+
+// newRosterIndex は名簿の表示名と別名の索引を作成します
+func newRosterIndex(members []models.RosterMember) rosterIndex {
+	index := make(rosterIndex, len(members))
+	for i := range members {
+		for _, name := range memberNames(members[i]) {
+			index[models.NormalizeName(name)] = &members[i]
+		}
+	}
+	return index
+}
+
+// match は名前に一致する名簿のメンバーを返します（一致しなければ nil）
+func (idx rosterIndex) match(name string) *models.RosterMember {
+	return idx[models.NormalizeName(name)]
+}
+
+// loadRoster は団体の名簿を別名・既定の演目付きで取得します
+func (h *Handler) loadRoster(db *gorm.DB, orgID uint) ([]models.RosterMember, error) {
+	var members []models.RosterMember
+	err := db.Preload("Aliases").Preload("DefaultPerformances").
+		Where("organization_id = ?", orgID).Order("display_name").Find(&members).Error
+	return members, err
+}
+
+// responseIdentity は回答の名前と名簿のメンバーを決めます
+// 名簿と結び付いたイベントでは member_id、ログイン中のアカウント、名前・別名の順にメンバーを探し、
+// 名簿にない名前はゲストとして受け付けます。同じメンバー・同じ名前（models.NormalizeName で比較）の
+// 2件目の回答は 409 です
+// 失敗した場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) responseIdentity(c *gin.Context, event models.Event, req models.CreateResponseRequest, responseID uint) (string, *uint, bool) {
+	name := strings.TrimSpace(req.Name)
+
+	if !event.UseRoster || event.OrganizationID == nil {
+		if req.MemberID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This event is not linked to a roster"})
+			return "", nil, false
+		}
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return "", nil, false
+		}
+		if !h.checkDuplicateName(c, event.ID, name, responseID) {
+			return "", nil, false
+		}
+		return name, nil, true
+	}
+
+	roster, err := h.loadRoster(h.db, *event.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roster"})
+		return "", nil, false
+	}
+
+	var member *models.RosterMember
+	switch {
+	case req.MemberID != nil:
+		for i := range roster {
+			if roster[i].ID == *req.MemberID {
+				member = &roster[i]
+			}
+		}
+		if member == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown member"})
+			return "", nil, false
+		}
+	default:
+		if userID, ok := h.currentUserID(c); ok {
+			for i := range roster {
+				if roster[i].UserID != nil && *roster[i].UserID == userID {
+					member = &roster[i]
+				}
+			}
+		}
+		if member == nil {
+			member = newRosterIndex(roster).match(name)
+		}
+	}
+
+	if member == nil {
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return "", nil, false
+		}
+		if !h.checkDuplicateName(c, event.ID, name, responseID) {
+			return "", nil, false
+		}
+		return name, nil, true
+	}
+
+	if !checkMemberResponse(c, h.db, event.ID, member.ID, responseID) {
+		return "", nil, false
+	}
+
+	if name == "" {
+		name = member.DisplayName
+	}
+	if !h.checkDuplicateName(c, event.ID, name, responseID) {
+		return "", nil, false
+	}
+	return name, &member.ID, true
+}
+
+// checkMemberResponse は名簿のメンバーが同じイベントに既に回答していないかを確認します
+// responseID の回答（更新中の回答）は除きます
+// 回答済みの場合は既存の回答の ID を付けて 409 を書き込み false を返します
+// 保存するトランザクションではイベントをロックしてから db に tx を渡して確かめ直します
+func checkMemberResponse(c *gin.Context, db *gorm.DB, eventID string, memberID, responseID uint) bool {
+	var responses []models.Response
+	err := db.Select("id", "name").
+		Where("event_id = ? AND member_id = ? AND id <> ?", eventID, memberID, responseID).
+		Limit(1).Find(&responses).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check responses"})
+		return false
+	}
+	if len(responses) == 0 {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       fmt.Sprintf("%q has already responded to this event", responses[0].Name),
+		"response_id": responses[0].ID,
+	})
+	return false
+}
+
+// rosterConflict は名簿の他のメンバーと重なる名前を返します（重ならなければ空文字列）
+func rosterConflict(roster []models.RosterMember, memberID uint, names []string) string {
+	others := make(rosterIndex)
+	for i := range roster {
+		if roster[i].ID == memberID {
+			continue
+		}
+		for _, name := range memberNames(roster[i]) {
+			others[models.NormalizeName(name)] = &roster[i]
+		}
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		key := models.NormalizeName(name)
+		if others[key] != nil || seen[key] {
+			return name
+		}
+		seen[key] = true
+	}
+	return ""
+}
+
+// saveRosterMember は名簿のメンバーを別名・既定の演目とともに保存します
+// 既存のメンバーの場合、別名と既定の演目は置き換えます
+func (h *Handler) saveRosterMember(c *gin.Context, member *models.RosterMember) bool {
+	var req models.RosterMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	member.DisplayName = strings.TrimSpace(req.DisplayName)
+	if member.DisplayName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "display_name is required"})
+		return false
+	}
+	member.UserID = req.UserID
+
+	names := []string{member.DisplayName}
+	member.Aliases = nil
+	for _, alias := range req.Aliases {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+			member.Aliases = append(member.Aliases, models.RosterAlias{Alias: alias})
+		}
+	}
+	member.DefaultPerformances = nil
+	for _, title := range req.DefaultPerformances {
+		if title = strings.TrimSpace(title); title != "" {
+			member.DefaultPerformances = append(member.DefaultPerformances, models.RosterPerformance{Title: title})
+		}
+	}
+
+	errNameTaken := errors.New("name taken")
+	var taken string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		roster, err := h.loadRoster(tx, member.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if taken = rosterConflict(roster, member.ID, names); taken != "" {
+			return errNameTaken
+		}
+
+		if member.ID != 0 {
+			if err := tx.Where("member_id = ?", member.ID).Delete(&models.RosterAlias{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("member_id = ?", member.ID).Delete(&models.RosterPerformance{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(member).Error
+	})
+	if errors.Is(err, errNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q is already used by another member", taken)})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save member"})
+		return false
+	}
+	return true
+}
+
+// findRosterMember は :memberId の名簿のメンバーを取得します
+// 見つからない場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) findRosterMember(c *gin.Context, orgID uint) (models.RosterMember, bool) {
+	var member models.RosterMember
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 64)
+	if err == nil {
+		err = h.db.Where("id = ? AND organization_id = ?", memberID, orgID).First(&member).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return member, false
+	}
+	return member, true
+}
+
+// GetRoster lists an organization's roster
+func (h *Handler) GetRoster(c *gin.Context) {
+	membership, ok := h.requireMember(c, models.RoleMember)
+	if !ok {
+		return
+	}
+
+	roster, err := h.loadRoster(h.db, membership.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roster"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roster)
+}
+
+// CreateRosterMember adds a member to an organization's roster
+func (h *Handler) CreateRosterMember(c *gin.Context) {
+	owner, ok := h.requireMember(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	member := models.RosterMember{OrganizationID: owner.OrganizationID, CreatedAt: time.Now()}
+	if !h.saveRosterMember(c, &member) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateRosterMember replaces a roster member's name, aliases and default performances
+func (h *Handler) UpdateRosterMember(c *gin.Context) {
+	owner, ok := h.requireMember(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	member, ok := h.findRosterMember(c, owner.OrganizationID)
+	if !ok {
+		return
+	}
+	if !h.saveRosterMember(c, &member) {
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// DeleteRosterMember removes a member from an organization's roster
+// 回答は残し、名簿との結び付きだけを外します
+func (h *Handler) DeleteRosterMember(c *gin.Context) {
+	owner, ok := h.requireMember(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	member, ok := h.findRosterMember(c, owner.OrganizationID)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Response{}).Where("member_id = ?", member.ID).Update("member_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("member_id = ?", member.ID).Delete(&models.RosterAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("member_id = ?", member.ID).Delete(&models.RosterPerformance{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&member).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member deleted successfully"})
+}
+
+// LinkEventRoster links or unlinks an event and its organization's roster
+// 結び付けるときは、既存の回答を名前・別名で名簿のメンバーと照合します
+func (h *Handler) LinkEventRoster(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if event.OrganizationID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event does not belong to an organization"})
+		return
+	}
+
+	var req models.LinkRosterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wasLinked := event.UseRoster
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&event).Update("use_roster", req.UseRoster).Error; err != nil {
+			return err
+		}
+		if !req.UseRoster {
+			return nil
+		}
+
+		roster, err := h.loadRoster(tx, *event.OrganizationID)
+		if err != nil {
+			return err
+		}
+		index := newRosterIndex(roster)
+
+		var responses []models.Response
+		if err := tx.Where("event_id = ? AND member_id IS NULL", event.ID).Order("id").Find(&responses).Error; err != nil {
+			return err
+		}
+		// 同じメンバーに一致する回答が複数ある場合は最初の回答だけを結び付ける
+		linked := make(map[uint]bool)
+		var existing []uint
+		if err := tx.Model(&models.Response{}).Where("event_id = ? AND member_id IS NOT NULL", event.ID).Pluck("member_id", &existing).Error; err != nil {
+			return err
+		}
+		for _, memberID := range existing {
+			linked[memberID] = true
+		}
+		for _, response := range responses {
+			member := index.match(response.Name)
+			if member == nil || linked[member.ID] {
+				continue
+			}
+			linked[member.ID] = true
+			if err := tx.Model(&response).Update("member_id", member.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	if req.UseRoster && !wasLinked {
+		h.publishRosterCompleted(c.Request.Context(), event)
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// publishRosterCompleted は名簿のメンバー全員が回答していれば roster.completed を通知します
+// 回答がメンバーに新しく結び付いたときにだけ呼び出し、同じ通知を何度も送らないようにします
+func (h *Handler) publishRosterCompleted(ctx context.Context, event models.Event) {
+	if !event.UseRoster || event.OrganizationID == nil {
+		return
+	}
+
+	var members, responded int64
+	if err := h.db.Model(&models.RosterMember{}).Where("organization_id = ?", *event.OrganizationID).Count(&members).Error; err != nil || members == 0 {
+		return
+	}
+	err := h.db.Model(&models.Response{}).
+		Where("event_id = ? AND member_id IN (?)", event.ID,
+			h.db.Model(&models.RosterMember{}).Select("id").Where("organization_id = ?", *event.OrganizationID)).
+		Distinct("member_id").Count(&responded).Error
+	if err != nil || responded < members {
+		return
+	}
+
+	h.publish(ctx, event.ID, realtime.Message{Type: realtime.RosterCompleted, Data: gin.H{"members": members}})
+}
+
+// GetEventRoster shows which roster members have responded to an event
+// 各メンバーの既定の演目は、このイベントのパフォーマンスとタイトルで照合した ID で返します
+func (h *Handler) GetEventRoster(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Performances").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !event.UseRoster || event.OrganizationID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This event is not linked to a roster"})
+		return
+	}
+
+	roster, err := h.loadRoster(h.db, *event.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roster"})
+		return
+	}
+	var responses []models.Response
+	if err := h.db.Where("event_id = ?", id).Order("id").Find(&responses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get responses"})
+		return
+	}
+
+	perfIDs := make(map[string]uint, len(event.Performances))
+	for _, perf := range event.Performances {
+		perfIDs[models.NormalizeName(perf.Title)] = perf.ID
+	}
+	responded := make(map[uint]uint) // メンバーID -> 回答ID
+	type guest struct {
+		ResponseID uint   `json:"response_id"`
+		Name       string `json:"name"`
+	}
+	guests := make([]guest, 0)
+	for _, response := range responses {
+		if response.MemberID != nil {
+			responded[*response.MemberID] = response.ID
+		} else {
+			guests = append(guests, guest{ResponseID: response.ID, Name: response.Name})
+		}
+	}
+
+	type memberStatus struct {
+		MemberID            uint   `json:"member_id"`
+		DisplayName         string `json:"display_name"`
+		Responded           bool   `json:"responded"`
+		ResponseID          *uint  `json:"response_id,omitempty"`
+		DefaultPerformances []uint `json:"default_performances"`
+	}
+	members := make([]memberStatus, 0, len(roster))
+	missing := make([]string, 0)
+	for _, member := range roster {
+		status := memberStatus{
+			MemberID:            member.ID,
+			DisplayName:         member.DisplayName,
+			DefaultPerformances: make([]uint, 0, len(member.DefaultPerformances)),
+		}
+		if responseID, ok := responded[member.ID]; ok {
+			status.Responded = true
+			status.ResponseID = &responseID
+		} else {
+			missing = append(missing, member.DisplayName)
+		}
+		for _, perf := range member.DefaultPerformances {
+			if perfID, ok := perfIDs[models.NormalizeName(perf.Title)]; ok {
+				status.DefaultPerformances = append(status.DefaultPerformances, perfID)
+			}
+		}
+		members = append(members, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"missing": missing,
+		"guests":  guests,
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"gorm.io/gorm/clause"
+)
+
+// loadConfirmedSchedule はイベントの確定スケジュールを日付・パフォーマンス付きで取得します
+func (h *Handler) loadConfirmedSchedule(eventID string) ([]models.ScheduledSession, error) {
+	var sessions []models.ScheduledSession
+	err := h.db.Preload("Performance").Preload("Date").
+		Where("event_id = ?", eventID).
+		Order("date_id, performance_id, session").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// GetSchedule retrieves the confirmed schedule of an event
+func (h *Handler) GetSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	sessions, err := h.loadConfirmedSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// ConfirmSchedule replaces the confirmed schedule of an event
+func (h *Handler) ConfirmSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	var event models.Event
+	if err := h.db.Preload("Dates").Preload("Performances").Where("id = ?", id).First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var req models.ConfirmScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 日付・パフォーマンスがこのイベントのものか確認
+	dateIDs := make(map[uint]bool, len(event.Dates))
+	for _, date := range event.Dates {
+		dateIDs[date.ID] = true
+	}
+	perfIDs := make(map[uint]bool, len(event.Performances))
+	for _, perf := range event.Performances {
+		perfIDs[perf.ID] = true
+	}
+
+	// セッション番号はカレンダーの UID に使うため、パフォーマンスごとに重複させない
+	type sessionKey struct {
+		performanceID uint
+		session       int
+	}
+	seen := make(map[sessionKey]bool, len(req.Sessions))
+	sessions := make([]models.ScheduledSession, 0, len(req.Sessions))
+	for _, s := range req.Sessions {
+		if !perfIDs[s.PerformanceID] || !dateIDs[s.DateID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown performance or date"})
+			return
+		}
+		// 省略した場合は 1 回目とみなす
+		session := s.Session
+		if session == 0 {
+			session = 1
+		}
+		if session < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session must be a positive number"})
+			return
+		}
+		key := sessionKey{s.PerformanceID, session}
+		if seen[key] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":          "Duplicate session number for a performance",
+				"performance_id": s.PerformanceID,
+				"session":        session,
+			})
+			return
+		}
+		seen[key] = true
+		sessions = append(sessions, models.ScheduledSession{
+			EventID:       id,
+			PerformanceID: s.PerformanceID,
+			DateID:        s.DateID,
+			Session:       session,
+		})
+	}
+
+	// 同時に確定した場合も通知が重ならないよう、イベントの行をロックしてから前の日程と比べる
+	tx := h.db.Begin()
+	var previous []models.ScheduledSession
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", id).Take(&models.Event{}).Error
+	if err == nil {
+		err = tx.Where("event_id = ?", id).Find(&previous).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm schedule"})
+		return
+	}
+	changed := !sameSessions(previous, sessions)
+
+	if err := tx.Where("event_id = ?", id).Delete(&models.ScheduledSession{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm schedule"})
+		return
+	}
+	if len(sessions) > 0 {
+		if err := tx.Create(&sessions).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm schedule"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm schedule"})
+		return
+	}
+
+	confirmed, err := h.loadConfirmedSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get schedule"})
+		return
+	}
+
+	// 同じ日程を確定し直した場合は通知しない
+	if changed {
+		h.publish(c.Request.Context(), id, realtime.Message{Type: realtime.ScheduleConfirmed, Data: gin.H{"sessions": confirmed}})
+		h.notifyScheduleConfirmed(c.Request.Context(), event, confirmed)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": confirmed})
+}
+
+// sameSessions は2つの確定した日程が同じパフォーマンス・日付・練習回の組み合わせかを返します
+func sameSessions(a, b []models.ScheduledSession) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	type key struct {
+		performanceID, dateID uint
+		session               int
+	}
+	counts := make(map[key]int, len(a))
+	for _, s := range a {
+		counts[key{s.PerformanceID, s.DateID, s.Session}]++
+	}
+	for _, s := range b {
+		k := key{s.PerformanceID, s.DateID, s.Session}
+		if counts[k] == 0 {
+			return false
+		}
+		counts[k]--
+	}
+	return true
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/timeslot"
+	"gorm.io/gorm"
+)
+
+// applyTemplate はイベント作成リクエストの未指定の項目をテンプレートで補います
+func applyTemplate(req *models.CreateEventRequest, template models.EventTemplate) {
+	if req.Title == "" {
+		req.Title = template.Title
+	}
+	if req.Description == "" {
+		req.Description = template.Description
+	}
+	if len(req.Performances) == 0 {
+		for _, perf := range template.Performances {
+			req.Performances = append(req.Performances, models.PerformanceRequest{
+				Title:       perf.Title,
+				Description: perf.Description,
+			})
+		}
+	}
+}
+
+// visibleTemplates はユーザーが作成したテンプレートと、所属する団体で共有されたテンプレートに絞り込みます
+func (h *Handler) visibleTemplates(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		orgIDs := h.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", userID)
+		return db.Where("created_by = ? OR organization_id IN (?)", userID, orgIDs)
+	}
+}
+
+// findTemplate はログインしているユーザーが使える id のテンプレートを取得します
+// 見つからない場合や他のユーザーのテンプレートの場合はエラーレスポンスを書き込み false を返します
+func (h *Handler) findTemplate(c *gin.Context, id any) (models.EventTemplate, bool) {
+	var template models.EventTemplate
+	user, ok := h.requireUser(c)
+	if !ok {
+		return template, false
+	}
+	if err := h.db.Scopes(h.visibleTemplates(user.ID)).Preload("Performances").First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return template, false
+	}
+	return template, true
+}
+
+// CreateTemplate saves an event template from the request or from an existing event
+// event_id のイベントは閲覧でき、団体のイベントならそのメンバーである必要があります
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OrganizationID != nil && !h.isMember(*req.OrganizationID, user.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	template := models.EventTemplate{
+		Name:           req.Name,
+		Title:          req.Title,
+		Description:    req.Description,
+		CreatedBy:      &user.ID,
+		OrganizationID: req.OrganizationID,
+		CreatedAt:      time.Now(),
+	}
+
+	if req.EventID != "" {
+		var event models.Event
+		// 閲覧できないイベントは存在を明かさない
+		if err := h.db.Preload("Performances").Where("id = ?", req.EventID).First(&event).Error; err != nil || !h.canView(c, event) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if !h.requireOrganizer(c, event) {
+			return
+		}
+		if template.Title == "" {
+			template.Title = event.Title
+		}
+		if template.Description == "" {
+			template.Description = event.Description
+		}
+		if len(req.Performances) == 0 {
+			for _, perf := range event.Performances {
+				req.Performances = append(req.Performances, models.PerformanceRequest{
+					Title:       perf.Title,
+					Description: perf.Description,
+				})
+			}
+		}
+	}
+
+	if !checkLimit(c, "performances", len(req.Performances), h.cfg.MaxPerformances) {
+		return
+	}
+	for _, perf := range req.Performances {
+		template.Performances = append(template.Performances, models.TemplatePerformance{
+			Title:       perf.Title,
+			Description: perf.Description,
+		})
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// GetTemplates lists the event templates the logged-in user can use
+func (h *Handler) GetTemplates(c *gin.Context) {
+	user, ok := h.requireUser(c)
+	if !ok {
+		return
+	}
+
+	var templates []models.EventTemplate
+	if err := h.db.Scopes(h.visibleTemplates(user.ID)).Preload("Performances").Order("name").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate retrieves an event template
+func (h *Handler) GetTemplate(c *gin.Context) {
+	template, ok := h.findTemplate(c, c.Param("templateId"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate deletes an event template with its performances
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	template, ok := h.findTemplate(c, c.Param("templateId"))
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", template.ID).Delete(&models.TemplatePerformance{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&template).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// shiftDateValue は Date.Value を weeks 週ずらします
+// 解釈できない値はそのまま返します
+func shiftDateValue(value string, weeks int, loc *time.Location) string {
+	if weeks == 0 {
+		return value
+	}
+	slot, err := timeslot.Parse(value, loc)
+	if err != nil {
+		return value
+	}
+	// 壁時計の時刻を保つため AddDate でずらす
+	shifted := timeslot.Slot{
+		Start: slot.Start.AddDate(0, 0, 7*weeks),
+		End:   slot.End.AddDate(0, 0, 7*weeks),
+	}
+	return timeslot.Format(shifted, loc)
+}
+
+// CloneEvent creates a new event from an existing one
+// 日程は shift_weeks 週ずらし、keep_performances=false でパフォーマンスを引き継がず、
+// invite_members=true で元の回答者を参加演目付き・回答なしで登録します
+func (h *Handler) CloneEvent(c *gin.Context) {
+	id := c.Param("id")
+
+	var source models.Event
+	if err := h.db.Preload("Dates").Preload("Performances").Where("id = ?", id).First(&source).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	// ボディを省略した場合は既定の設定で複製する
+	var req models.CloneEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	keepPerformances := req.KeepPerformances == nil || *req.KeepPerformances
+
+	event := models.Event{
+		Title:       source.Title,
+		Description: source.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		// 閲覧パスワードは引き継がない（必要なら複製したイベントで設定し直す）
+	}
+	// 団体のメンバーが複製した場合は同じ団体のイベントにする
+	if userID, ok := h.currentUserID(c); ok && source.OrganizationID != nil && h.isMember(*source.OrganizationID, userID) {
+		event.OrganizationID = source.OrganizationID
+		event.UseRoster = source.UseRoster
+	}
+	if req.Title != "" {
+		event.Title = req.Title
+	}
+
+	if !checkLimit(c, "dates", len(source.Dates), h.cfg.MaxDates) {
+		return
+	}
+	if keepPerformances && !checkLimit(c, "performances", len(source.Performances), h.cfg.MaxPerformances) {
+		return
+	}
+
+	loc := h.location()
+	for _, date := range source.Dates {
+		event.Dates = append(event.Dates, models.Date{
+			Value: shiftDateValue(date.Value, req.ShiftWeeks, loc),
+		})
+	}
+	if keepPerformances {
+		for _, perf := range source.Performances {
+			event.Performances = append(event.Performances, models.Performance{
+				Title:       perf.Title,
+				Description: perf.Description,
+			})
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.insertEvent(tx, &event); err != nil {
+			return err
+		}
+		if !req.InviteMembers {
+			return nil
+		}
+
+		// 元のパフォーマンス ID -> 新しいパフォーマンス ID
+		perfIDs := make(map[uint]uint, len(source.Performances))
+		for i, perf := range event.Performances {
+			perfIDs[source.Performances[i].ID] = perf.ID
+		}
+
+		var responses []models.Response
+		if err := tx.Preload("Performances").Where("event_id = ?", source.ID).Order("id").Find(&responses).Error; err != nil {
+			return err
+		}
+		// 一意にする前からの重複は1件だけ招待する
+		invitedNames := make(map[string]bool, len(responses))
+		for _, r := range responses {
+			key := models.NormalizeName(r.Name)
+			if invitedNames[key] {
+				continue
+			}
+			invitedNames[key] = true
+			invited := models.Response{
+				EventID:   event.ID,
+				Name:      r.Name,
+				CreatedAt: time.Now(),
+			}
+			for _, up := range r.Performances {
+				if newID, ok := perfIDs[up.PerformanceID]; ok {
+					invited.Performances = append(invited.Performances, models.UserPerformance{PerformanceID: newID})
+				}
+			}
+			if err := tx.Create(&invited).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone event"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, event)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/webhooks"
+	"gorm.io/gorm"
+)
+
+// maxWebhookDeliveries は配信ログで返す件数です
+const maxWebhookDeliveries = 50
+
+// findWebhook は :webhookId の Webhook を取得します。イベントに属さない場合は 404 を書き込みます
+func (h *Handler) findWebhook(c *gin.Context, eventID string) (models.Webhook, bool) {
+	var hook models.Webhook
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return hook, false
+	}
+	if err := h.db.Where("id = ? AND event_id = ?", webhookID, eventID).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return hook, false
+	}
+	return hook, true
+}
+
+// CreateWebhook subscribes a URL to an event's activity
+// 署名用の鍵はこのレスポンスでだけ返します
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http or https URL"})
+		return
+	}
+	for _, t := range req.Events {
+		if !webhooks.ValidType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("unknown event type %q (expected one of %s)", t, strings.Join(webhooks.Types, ", ")),
+			})
+			return
+		}
+	}
+
+	hook := models.Webhook{
+		EventID:   event.ID,
+		URL:       req.URL,
+		Secret:    webhooks.NewSecret(),
+		Events:    strings.Join(req.Events, ","),
+		Types:     req.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+// GetWebhooks lists an event's webhook subscriptions
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+
+	var hooks []models.Webhook
+	if err := h.db.Where("event_id = ?", event.ID).Order("id").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks"})
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// DeleteWebhook removes a webhook subscription with its delivery log
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+	hook, ok := h.findWebhook(c, event.ID)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries lists the most recent deliveries of a webhook
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+	hook, ok := h.findWebhook(c, event.ID)
+	if !ok {
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := h.db.Where("webhook_id = ?", hook.ID).Order("id DESC").Limit(maxWebhookDeliveries).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhook queues a ping delivery so the receiver can check signatures end to end
+// 結果は配信ログで確認します
+func (h *Handler) TestWebhook(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		return
+	}
+	hook, ok := h.findWebhook(c, event.ID)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.cfg.Webhooks.EnqueueTo(hook, webhooks.Ping, gin.H{"webhook_id": hook.ID, "title": event.Title})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,94 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// productID は PRODID に使う識別子です
+const productID = "-//schedule-app//Schedule-App//JA"
+
+// Event は VEVENT 1つ分です
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+}
+
+// Calendar は VCALENDAR 1つ分です
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Encode は RFC 5545 形式（CRLF 改行・75 オクテットで折り返し）にエンコードします
+func (cal Calendar) Encode() []byte {
+	var buf bytes.Buffer
+	stamp := time.Now()
+
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:"+productID)
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	if cal.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+escapeText(cal.Name))
+	}
+
+	for _, ev := range cal.Events {
+		writeLine(&buf, "BEGIN:VEVENT")
+		writeLine(&buf, "UID:"+ev.UID)
+		writeLine(&buf, "DTSTAMP:"+formatUTC(stamp))
+		writeLine(&buf, "DTSTART:"+formatUTC(ev.Start))
+		writeLine(&buf, "DTEND:"+formatUTC(ev.End))
+		if !ev.LastModified.IsZero() {
+			writeLine(&buf, "LAST-MODIFIED:"+formatUTC(ev.LastModified))
+		}
+		writeLine(&buf, "SUMMARY:"+escapeText(ev.Summary))
+		if ev.Description != "" {
+			writeLine(&buf, "DESCRIPTION:"+escapeText(ev.Description))
+		}
+		writeLine(&buf, "END:VEVENT")
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// formatUTC は日時を UTC の DATE-TIME 形式に変換します
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText は TEXT 値の特殊文字をエスケープします
+func escapeText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// writeLine はコンテンツ行を 75 オクテットごとに折り返して書き込みます
+// マルチバイト文字の途中では折り返しません
+func writeLine(buf *bytes.Buffer, line string) {
+	const limit = 75
+
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > limit {
+			buf.WriteString("\r\n ")
+			width = 1 // 継続行の先頭の空白
+		}
+		buf.WriteRune(r)
+		width += size
+	}
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,490 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/recurrence"
+)
+
+// Period は開始・終了時刻で表した時間帯です
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// FreeBusy は iCalendar から読み取った予定の入っている時間帯と空いている時間帯です
+// Busy は確定した予定と FBTYPE=BUSY / BUSY-UNAVAILABLE、Tentative は仮の予定
+// （STATUS:TENTATIVE と FBTYPE=BUSY-TENTATIVE）、Free は FBTYPE=FREE です
+type FreeBusy struct {
+	Busy      []Period
+	Tentative []Period
+	Free      []Period
+}
+
+// add は FBTYPE に応じて時間帯を振り分けます
+func (fb *FreeBusy) add(fbType string, periods ...Period) {
+	switch strings.ToUpper(fbType) {
+	case "FREE":
+		fb.Free = append(fb.Free, periods...)
+	case "BUSY-TENTATIVE":
+		fb.Tentative = append(fb.Tentative, periods...)
+	default:
+		fb.Busy = append(fb.Busy, periods...)
+	}
+}
+
+// property はコンテンツ行1つ分です
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseFreeBusy は iCalendar を読み込み、予定の時間帯を取り出します
+// TZID 付きの日時はそのタイムゾーンで、タイムゾーンのない日時と終日の予定は loc で解釈します
+// 透過（TRANSP:TRANSPARENT）とキャンセル済みの予定は無視します
+// 繰り返しの予定（RRULE・RDATE）は window と重なる回だけに展開し、EXDATE と
+// 個別に変更された回（RECURRENCE-ID）を除きます。展開できない RRULE はエラーになります
+func ParseFreeBusy(r io.Reader, loc *time.Location, window Period) (FreeBusy, error) {
+	props, err := readProperties(r)
+	if err != nil {
+		return FreeBusy{}, err
+	}
+
+	var fb FreeBusy
+	var stack []string
+	var event []property
+	var events []vevent
+	for _, prop := range props {
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.value))
+			if prop.value == "VEVENT" {
+				event = event[:0]
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return FreeBusy{}, fmt.Errorf("unexpected END:%s", prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			if prop.value == "VEVENT" {
+				ev, err := parseEvent(event, loc)
+				if err != nil {
+					return FreeBusy{}, err
+				}
+				events = append(events, ev)
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			continue
+		}
+		switch stack[len(stack)-1] {
+		case "VEVENT":
+			event = append(event, prop)
+		case "VFREEBUSY":
+			if prop.name != "FREEBUSY" {
+				continue
+			}
+			periods, err := parsePeriods(prop, loc)
+			if err != nil {
+				return FreeBusy{}, err
+			}
+			fb.add(prop.params["FBTYPE"], periods...)
+		}
+	}
+	if len(stack) > 0 {
+		return FreeBusy{}, fmt.Errorf("missing END:%s", stack[len(stack)-1])
+	}
+
+	// 個別に変更・キャンセルされた回は、元の繰り返しからは展開しない
+	overridden := make(map[string][]recurrence.Instant)
+	for _, ev := range events {
+		if ev.recurrenceID != nil {
+			overridden[ev.uid] = append(overridden[ev.uid], *ev.recurrenceID)
+		}
+	}
+	for _, ev := range events {
+		if ev.fbType == "" {
+			continue
+		}
+		periods, err := ev.instances(loc, window, overridden[ev.uid])
+		if err != nil {
+			return FreeBusy{}, err
+		}
+		fb.add(ev.fbType, periods...)
+	}
+	return fb, nil
+}
+
+// vevent は VEVENT 1つ分の時間帯と繰り返しの指定です
+type vevent struct {
+	uid    string
+	period Period
+	allDay bool
+	// fbType は FBTYPE 相当の種類（予定として扱わない透過・キャンセル済みの場合は空）
+	fbType string
+
+	recurrenceID *recurrence.Instant
+	rrule        *property
+	rdates       []property
+	exdates      []property
+}
+
+// parseEvent は VEVENT のプロパティを解釈します
+func parseEvent(props []property, loc *time.Location) (vevent, error) {
+	var start, end, recurrenceID *property
+	var duration string
+	ev := vevent{fbType: "BUSY"}
+	for i := range props {
+		prop := &props[i]
+		switch prop.name {
+		case "UID":
+			ev.uid = prop.value
+		case "DTSTART":
+			start = prop
+		case "DTEND":
+			end = prop
+		case "DURATION":
+			duration = prop.value
+		case "RECURRENCE-ID":
+			recurrenceID = prop
+		case "RRULE":
+			// props は次の VEVENT で再利用されるため、コピーを保持する
+			rule := *prop
+			ev.rrule = &rule
+		case "RDATE":
+			ev.rdates = append(ev.rdates, *prop)
+		case "EXDATE":
+			ev.exdates = append(ev.exdates, *prop)
+		case "TRANSP":
+			if strings.EqualFold(prop.value, "TRANSPARENT") {
+				ev.fbType = ""
+			}
+		case "STATUS":
+			switch strings.ToUpper(prop.value) {
+			case "CANCELLED":
+				ev.fbType = ""
+			case "TENTATIVE":
+				if ev.fbType != "" {
+					ev.fbType = "BUSY-TENTATIVE"
+				}
+			}
+		}
+	}
+
+	if recurrenceID != nil {
+		t, allDay, err := parseDateTime(*recurrenceID, loc)
+		if err != nil {
+			return ev, err
+		}
+		ev.recurrenceID = &recurrence.Instant{Time: t, AllDay: allDay}
+	}
+	if start == nil {
+		// 予定として扱わない VEVENT は日時がなくてもよい
+		if ev.fbType == "" {
+			return ev, nil
+		}
+		return ev, fmt.Errorf("VEVENT without DTSTART")
+	}
+
+	startTime, allDay, err := parseDateTime(*start, loc)
+	if err != nil {
+		return ev, err
+	}
+
+	var endTime time.Time
+	switch {
+	case end != nil:
+		if endTime, _, err = parseDateTime(*end, loc); err != nil {
+			return ev, err
+		}
+	case duration != "":
+		d, err := parseDuration(duration)
+		if err != nil {
+			return ev, err
+		}
+		endTime = startTime.Add(d)
+	case allDay:
+		endTime = startTime.AddDate(0, 0, 1)
+	default:
+		endTime = startTime
+	}
+	ev.period = Period{Start: startTime, End: endTime}
+	ev.allDay = allDay
+	return ev, nil
+}
+
+// instances は予定の各回の時間帯を返します
+// 繰り返しのない予定はそのまま、繰り返す予定は window と重なる回だけを返します
+func (ev vevent) instances(loc *time.Location, window Period, overridden []recurrence.Instant) ([]Period, error) {
+	if ev.rrule == nil && len(ev.rdates) == 0 {
+		return []Period{ev.period}, nil
+	}
+
+	// 終日の予定は日数で、それ以外は長さで各回の終わりを決める（夏時間をまたいでも日数は同じ）
+	days := int(ev.period.End.Sub(ev.period.Start).Hours()+12) / 24
+	length := ev.period.End.Sub(ev.period.Start)
+	periodFrom := func(start time.Time) Period {
+		if ev.allDay {
+			return Period{Start: start, End: start.AddDate(0, 0, days)}
+		}
+		return Period{Start: start, End: start.Add(length)}
+	}
+
+	set := recurrence.Set{Start: ev.period.Start, ExDates: overridden}
+	if ev.rrule != nil {
+		rule, err := recurrence.Parse(ev.rrule.value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("RRULE: %w", err)
+		}
+		set.Rule = &rule
+	}
+
+	// 期間で指定された RDATE はそれぞれの終わりを使う
+	var periods []Period
+	for _, prop := range ev.rdates {
+		if strings.EqualFold(prop.params["VALUE"], "PERIOD") {
+			rdatePeriods, err := parsePeriods(prop, loc)
+			if err != nil {
+				return nil, err
+			}
+			periods = append(periods, rdatePeriods...)
+			continue
+		}
+		rdates, err := parseInstants(prop, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, rdate := range rdates {
+			set.RDates = append(set.RDates, rdate.Time)
+		}
+	}
+	for _, prop := range ev.exdates {
+		exdates, err := parseInstants(prop, loc)
+		if err != nil {
+			return nil, err
+		}
+		set.ExDates = append(set.ExDates, exdates...)
+	}
+
+	starts, err := set.Starts(window.End)
+	if err != nil {
+		return nil, err
+	}
+	for _, start := range starts {
+		periods = append(periods, periodFrom(start))
+	}
+
+	result := make([]Period, 0, len(periods))
+	for _, p := range periods {
+		if !p.End.After(window.Start) || !p.Start.Before(window.End) || set.Excluded(p.Start) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// parseInstants は EXDATE・RDATE のようなカンマ区切りの日時を解釈します
+func parseInstants(prop property, loc *time.Location) ([]recurrence.Instant, error) {
+	var instants []recurrence.Instant
+	for _, value := range strings.Split(prop.value, ",") {
+		t, allDay, err := parseDateTime(property{params: prop.params, value: strings.TrimSpace(value)}, loc)
+		if err != nil {
+			return nil, err
+		}
+		instants = append(instants, recurrence.Instant{Time: t, AllDay: allDay})
+	}
+	return instants, nil
+}
+
+// parsePeriods は FREEBUSY の値（"開始/終了" または "開始/期間" のカンマ区切り）を解釈します
+func parsePeriods(prop property, loc *time.Location) ([]Period, error) {
+	var periods []Period
+	for _, value := range strings.Split(prop.value, ",") {
+		startStr, endStr, found := strings.Cut(strings.TrimSpace(value), "/")
+		if !found {
+			return nil, fmt.Errorf("invalid period %q", value)
+		}
+		start, _, err := parseDateTime(property{value: startStr}, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		var end time.Time
+		if strings.HasPrefix(endStr, "P") || strings.HasPrefix(endStr, "+P") {
+			d, err := parseDuration(endStr)
+			if err != nil {
+				return nil, err
+			}
+			end = start.Add(d)
+		} else if end, _, err = parseDateTime(property{value: endStr}, loc); err != nil {
+			return nil, err
+		}
+		periods = append(periods, Period{Start: start, End: end})
+	}
+	return periods, nil
+}
+
+// parseDateTime は DATE-TIME（UTC・TZID 付き・ローカル）または DATE を解釈します
+// DATE の場合は true を返します
+func parseDateTime(prop property, loc *time.Location) (time.Time, bool, error) {
+	value := prop.value
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+			loc = tz
+		}
+	}
+
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+		}
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, false, nil
+}
+
+// parseDuration は "PT1H30M" や "P1D" のような期間を解釈します
+func parseDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(value, "+")
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		num = ""
+
+		var unit time.Duration
+		switch {
+		case r == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case r == 'H' && inTime:
+			unit = time.Hour
+		case r == 'M' && inTime:
+			unit = time.Minute
+		case r == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if num != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// readProperties は折り返しを戻してコンテンツ行を読み込みます
+func readProperties(r io.Reader) ([]property, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		lines[0] = strings.TrimPrefix(lines[0], utf8BOM)
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	props := make([]property, 0, len(lines))
+	for _, line := range lines {
+		props = append(props, parseProperty(line))
+	}
+	return props, nil
+}
+
+// utf8BOM は一部のアプリが先頭に付ける BOM です
+const utf8BOM = "\xEF\xBB\xBF"
+
+// parseProperty は "NAME;PARAM=VALUE:値" を分解します
+func parseProperty(line string) property {
+	// 値の区切りは引用符の外にある最初のコロン
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+
+	head, value := line, ""
+	if colon >= 0 {
+		head, value = line[:colon], line[colon+1:]
+	}
+
+	parts := strings.Split(head, ";")
+	prop := property{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: value}
+	for _, part := range parts[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		prop.params[strings.ToUpper(key)] = val
+	}
+	if prop.name == "BEGIN" || prop.name == "END" {
+		prop.value = strings.ToUpper(prop.value)
+	}
+	return prop
+}
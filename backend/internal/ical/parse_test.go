@@ -0,0 +1,92 @@
+package ical
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatPeriods は比較しやすいよう時間帯を loc の "01/02 15:04-15:04" 形式にします
+func formatPeriods(periods []Period, loc *time.Location) []string {
+	out := make([]string, 0, len(periods))
+	for _, p := range periods {
+		out = append(out, p.Start.In(loc).Format("01/02 15:04")+"-"+p.End.In(loc).Format("15:04"))
+	}
+	return out
+}
+
+func TestParseFreeBusyExpandsRecurrences(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/recurring.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	window := Period{
+		Start: time.Date(2025, 5, 1, 0, 0, 0, 0, tokyo),
+		End:   time.Date(2025, 7, 1, 0, 0, 0, 0, tokyo),
+	}
+	fb, err := ParseFreeBusy(f, tokyo, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBusy := []string{
+		// 毎週火曜のレッスン: 5/13 は EXDATE、5/20 は 5/21 に変更、4/29 は期間外
+		"05/06 19:00-21:00", "05/27 19:00-21:00", "06/03 19:00-21:00",
+		"06/10 19:00-21:00", "06/17 19:00-21:00", "06/24 19:00-21:00",
+		// 毎月31日（31日のない月は飛ばす）と RDATE
+		"05/31 18:00-19:00", "06/30 18:00-19:00",
+		// 変更された回と繰り返しのない予定
+		"05/21 19:00-21:00",
+		"05/02 10:00-11:00",
+	}
+	if got := formatPeriods(fb.Busy, tokyo); !sameElements(got, wantBusy) {
+		t.Errorf("busy = %v, want %v", got, wantBusy)
+	}
+
+	// 終日の仮の予定を COUNT=3 で繰り返す
+	wantTentative := []string{"05/10 00:00-00:00", "05/11 00:00-00:00", "05/12 00:00-00:00"}
+	if got := formatPeriods(fb.Tentative, tokyo); !sameElements(got, wantTentative) {
+		t.Errorf("tentative = %v, want %v", got, wantTentative)
+	}
+}
+
+func TestParseFreeBusyRejectsUnsupportedRules(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART:20250505T100000Z",
+		"DTEND:20250505T110000Z",
+		"RRULE:FREQ=MONTHLY;BYDAY=1MO",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	window := Period{Start: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := ParseFreeBusy(strings.NewReader(ics), time.UTC, window); err == nil {
+		t.Fatal("expected an error for an RRULE that cannot be expanded")
+	}
+}
+
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+		if counts[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/algorithm"
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ジョブの状態
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// heartbeatInterval は待機中・実行中のジョブの途中経過をデータベースに書き込む間隔です
+// staleAfter の間更新のないジョブは、実行していたサーバーが止まったものとみなします
+const (
+	heartbeatInterval = 5 * time.Second
+	staleAfter        = 6 * heartbeatInterval
+)
+
+// interruptedError は中断されたジョブに記録するエラーです
+const interruptedError = "Interrupted: the server running this job stopped"
+
+var (
+	// ErrNotFound はジョブが存在しない場合のエラーです
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull はキューが満杯でジョブを受け付けられない場合のエラーです
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrFinished は既に終了したジョブをキャンセルしようとした場合のエラーです
+	ErrFinished = errors.New("job already finished")
+)
+
+// RunFunc はワーカー上で実行される最適化処理です
+// 戻り値の result は JSON にエンコードされて保存されます
+type RunFunc func(ctx context.Context, progress func(algorithm.Progress)) (result interface{}, err error)
+
+// job は実行中・待機中のジョブです
+type job struct {
+	mu     sync.Mutex
+	record models.OptimizationJob
+	run    RunFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (j *job) snapshot() models.OptimizationJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.record
+}
+
+// Manager は最適化ジョブをワーカープールで実行し、結果をデータベースに保存します
+type Manager struct {
+	db    *gorm.DB
+	queue chan *job
+
+	mu     sync.Mutex
+	active map[string]*job
+}
+
+// NewManager は workers 個のワーカーを起動したジョブマネージャーを作成します
+func NewManager(db *gorm.DB, workers int, queueSize int) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	m := &Manager{
+		db:     db,
+		queue:  make(chan *job, queueSize),
+		active: make(map[string]*job),
+	}
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	m.failStale(time.Now())
+	go m.heartbeat()
+	return m
+}
+
+// heartbeat は待機中・実行中のジョブの途中経過を定期的に保存し、
+// 他のサーバー（再起動前の自分を含む）で止まったままのジョブを失敗にします
+func (m *Manager) heartbeat() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.mu.Lock()
+		active := make([]*job, 0, len(m.active))
+		for _, j := range m.active {
+			active = append(active, j)
+		}
+		m.mu.Unlock()
+
+		for _, j := range active {
+			m.saveProgress(j)
+		}
+		m.failStale(now)
+	}
+}
+
+// saveProgress は終了していないジョブの状態と途中経過を書き込みます
+// 終了状態の保存と競合しても上書きしないよう、データベース上で終了していない行だけを更新します
+func (m *Manager) saveProgress(j *job) {
+	record := j.snapshot()
+	err := m.db.Model(&models.OptimizationJob{}).
+		Where("id = ? AND status IN ?", record.ID, []string{StatusQueued, StatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      record.Status,
+			"iteration":   record.Iteration,
+			"iterations":  record.Iterations,
+			"temperature": record.Temperature,
+			"energy":      record.Energy,
+			"best_energy": record.BestEnergy,
+			"started_at":  record.StartedAt,
+			"updated_at":  time.Now(),
+		}).Error
+	if err != nil {
+		slog.Error("Failed to save optimization job progress", "job_id", record.ID, "event_id", record.EventID, "error", err)
+	}
+}
+
+// failStale は一定時間更新のない待機中・実行中のジョブを失敗にします
+// ジョブはメモリ上でしか実行されないため、サーバーが止まると再開できません
+func (m *Manager) failStale(now time.Time) {
+	result := m.db.Model(&models.OptimizationJob{}).
+		Where("status IN ? AND (updated_at IS NULL OR updated_at < ?)", []string{StatusQueued, StatusRunning}, now.Add(-staleAfter)).
+		Updates(map[string]interface{}{
+			"status":      StatusFailed,
+			"error":       interruptedError,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		slog.Error("Failed to mark interrupted optimization jobs", "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		slog.Warn("Marked interrupted optimization jobs as failed", "jobs", result.RowsAffected)
+	}
+}
+
+// Submit はジョブをキューに登録します
+func (m *Manager) Submit(eventID string, sessionCount int, run RunFunc) (models.OptimizationJob, error) {
+	id, err := generateJobID()
+	if err != nil {
+		return models.OptimizationJob{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		record: models.OptimizationJob{
+			ID:           id,
+			EventID:      eventID,
+			Status:       StatusQueued,
+			SessionCount: sessionCount,
+			CreatedAt:    time.Now(),
+		},
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if err := m.db.Create(&j.record).Error; err != nil {
+		cancel()
+		return models.OptimizationJob{}, err
+	}
+
+	m.mu.Lock()
+	m.active[id] = j
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- j:
+	default:
+		// キューが満杯なら登録を取り消す
+		m.mu.Lock()
+		delete(m.active, id)
+		m.mu.Unlock()
+		cancel()
+		m.db.Delete(&models.OptimizationJob{}, "id = ?", id)
+		return models.OptimizationJob{}, ErrQueueFull
+	}
+
+	return j.snapshot(), nil
+}
+
+// Get はジョブの状態と、終了していれば保存された結果を返します
+// 終了した直後でまだ実行中の一覧に残っているジョブも、メモリ上の結果を返します
+func (m *Manager) Get(eventID, id string) (models.OptimizationJob, json.RawMessage, error) {
+	m.mu.Lock()
+	j, ok := m.active[id]
+	m.mu.Unlock()
+	if ok {
+		record := j.snapshot()
+		if record.EventID == eventID {
+			return record, recordResult(record), nil
+		}
+	}
+
+	var record models.OptimizationJob
+	if err := m.db.Where("id = ? AND event_id = ?", id, eventID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, nil, ErrNotFound
+		}
+		return record, nil, err
+	}
+
+	return record, recordResult(record), nil
+}
+
+// recordResult はジョブに保存された結果を返します（結果がなければ nil）
+func recordResult(record models.OptimizationJob) json.RawMessage {
+	if record.Result == "" {
+		return nil
+	}
+	return json.RawMessage(record.Result)
+}
+
+// Cancel は待機中・実行中のジョブをキャンセルします
+func (m *Manager) Cancel(eventID, id string) (models.OptimizationJob, error) {
+	m.mu.Lock()
+	j, ok := m.active[id]
+	m.mu.Unlock()
+	if ok && j.snapshot().EventID == eventID {
+		j.cancel()
+		return j.snapshot(), nil
+	}
+
+	var record models.OptimizationJob
+	if err := m.db.Where("id = ? AND event_id = ?", id, eventID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, ErrNotFound
+		}
+		return record, err
+	}
+	return record, ErrFinished
+}
+
+// worker はキューからジョブを取り出して順に実行します
+func (m *Manager) worker() {
+	for j := range m.queue {
+		m.process(j)
+	}
+}
+
+// process は1つのジョブを実行し、結果を保存します
+func (m *Manager) process(j *job) {
+	defer func() {
+		j.cancel()
+		m.mu.Lock()
+		delete(m.active, j.record.ID)
+		m.mu.Unlock()
+	}()
+
+	// 待機中にキャンセルされた場合は実行しない
+	if j.ctx.Err() != nil {
+		m.finish(j, StatusCancelled, nil, nil)
+		return
+	}
+
+	startedAt := time.Now()
+	j.mu.Lock()
+	j.record.Status = StatusRunning
+	j.record.StartedAt = &startedAt
+	j.mu.Unlock()
+	m.save(j)
+
+	result, err := j.run(j.ctx, func(p algorithm.Progress) {
+		j.mu.Lock()
+		j.record.Iteration = p.Iteration
+		j.record.Iterations = p.MaxIterations
+		j.record.Temperature = p.Temperature
+		j.record.Energy = p.Energy
+		j.record.BestEnergy = p.BestEnergy
+		j.mu.Unlock()
+	})
+
+	switch {
+	case err != nil:
+		m.finish(j, StatusFailed, nil, err)
+	case j.ctx.Err() != nil:
+		// キャンセル時も途中までの最良解を保存する
+		m.finish(j, StatusCancelled, result, nil)
+	default:
+		m.finish(j, StatusSucceeded, result, nil)
+	}
+}
+
+// finish はジョブを終了状態にして保存します
+func (m *Manager) finish(j *job, status string, result interface{}, runErr error) {
+	finishedAt := time.Now()
+
+	j.mu.Lock()
+	j.record.Status = status
+	j.record.FinishedAt = &finishedAt
+	if runErr != nil {
+		j.record.Error = runErr.Error()
+	}
+	if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			j.record.Status = StatusFailed
+			j.record.Error = "Failed to encode result"
+		} else {
+			j.record.Result = string(encoded)
+		}
+	}
+	j.mu.Unlock()
+
+	m.save(j)
+}
+
+// save はジョブの現在の状態をデータベースに書き込みます
+func (m *Manager) save(j *job) {
+	record := j.snapshot()
+	if err := m.db.Save(&record).Error; err != nil {
+		slog.Error("Failed to save optimization job", "job_id", record.ID, "event_id", record.EventID, "error", err)
+	}
+}
+
+// generateJobID はジョブIDを生成します
+func generateJobID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
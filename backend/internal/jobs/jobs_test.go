@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+// newActiveManager は record のジョブを実行中の一覧に持つマネージャーを作成します
+// 一覧にあるジョブの取得ではデータベースを使いません
+func newActiveManager(record models.OptimizationJob) *Manager {
+	return &Manager{
+		active: map[string]*job{record.ID: {record: record}},
+	}
+}
+
+func TestGetRunningJobHasNoResult(t *testing.T) {
+	startedAt := time.Now()
+	m := newActiveManager(models.OptimizationJob{
+		ID:        "job1",
+		EventID:   "event1",
+		Status:    StatusRunning,
+		StartedAt: &startedAt,
+		Iteration: 40,
+	})
+
+	record, result, err := m.Get("event1", "job1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if record.Status != StatusRunning || record.Iteration != 40 {
+		t.Errorf("record = %+v, want the running job with its progress", record)
+	}
+	if result != nil {
+		t.Errorf("result = %s, want none while running", result)
+	}
+}
+
+func TestGetFinishedJobStillActiveReturnsResult(t *testing.T) {
+	// finish で終了状態を保存してから process が一覧から外すまでの間に取得した場合
+	finishedAt := time.Now()
+	m := newActiveManager(models.OptimizationJob{
+		ID:         "job1",
+		EventID:    "event1",
+		Status:     StatusSucceeded,
+		FinishedAt: &finishedAt,
+		Result:     `{"sessions":[]}`,
+	})
+
+	record, result, err := m.Get("event1", "job1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if record.Status != StatusSucceeded {
+		t.Errorf("status = %s, want %s", record.Status, StatusSucceeded)
+	}
+	if string(result) != `{"sessions":[]}` {
+		t.Errorf("result = %s, want the job's result", result)
+	}
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/logging"
+)
+
+// Message は送信するメールです（本文はプレーンテキスト）
+type Message struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment はメールの添付ファイルです
+type Attachment struct {
+	Filename    string // 例: "schedule.ics"
+	ContentType string // 例: "text/calendar; charset=utf-8"
+	Data        []byte
+}
+
+// Sender はメールの送信方法です
+// 開発環境では LogSender、本番では SMTPSender などに差し替えます
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender はメールを送らずに宛先と件名だけをログへ出力します
+// 本文にはログインリンクのトークンなどが含まれるため出力しません（内容の確認には FileSender を使います）
+type LogSender struct{}
+
+// Send はメッセージの宛先と件名をログに出力します
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	logger := logging.FromContext(ctx)
+	logger.Info("mail", "mail_to", msg.To, "subject", msg.Subject, "body_bytes", len(msg.Body))
+	for _, a := range msg.Attachments {
+		logger.Info("mail attachment", "filename", a.Filename, "content_type", a.ContentType, "bytes", len(a.Data))
+	}
+	return nil
+}
+
+// FileSender はメールを送らずに .eml ファイルとしてディレクトリに保存します
+// 添付ファイルを含めた実際のメッセージをメールソフトで確認できるため、開発時に使います
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// Send はメッセージを Dir に保存します
+func (s FileSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, build(s.From, msg), 0o644); err != nil {
+		return fmt.Errorf("write mail to %s: %w", msg.To, err)
+	}
+	logging.FromContext(ctx).Info("mail saved", "mail_to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
+
+// SMTPSender は SMTP サーバー経由でメールを送ります
+type SMTPSender struct {
+	Host     string // 例: "smtp.example.com"
+	Port     string // 例: "587"
+	Username string // 空なら認証しない
+	Password string
+	From     string
+}
+
+// Send は msg を SMTP で送信します
+// net/smtp はコンテキストに対応していないため、ctx は送信前の確認にだけ使います
+func (s SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, s.Port)
+	if err := smtp.SendMail(addr, auth, s.From, []string{msg.To}, build(s.From, msg)); err != nil {
+		return fmt.Errorf("send mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// build は RFC 5322 のメッセージを組み立てます
+// 添付ファイルがある場合は multipart/mixed にし、添付ファイルは base64 で符号化します
+func build(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	body := strings.ReplaceAll(msg.Body, "\n", "\r\n")
+
+	if len(msg.Attachments) == 0 {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(body)
+		return []byte(b.String())
+	}
+
+	boundary := make([]byte, 12)
+	rand.Read(boundary)
+	sep := "mixed-" + hex.EncodeToString(boundary)
+	b.WriteString("Content-Type: multipart/mixed; boundary=\"" + sep + "\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString("--" + sep + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body + "\r\n")
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		filename := mime.QEncoding.Encode("utf-8", a.Filename)
+		b.WriteString("--" + sep + "\r\n")
+		b.WriteString("Content-Type: " + contentType + "\r\n")
+		b.WriteString("Content-Transfer-Encoding: base64\r\n")
+		b.WriteString("Content-Disposition: attachment; filename=\"" + filename + "\"\r\n")
+		b.WriteString("\r\n")
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			b.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		b.WriteString(encoded + "\r\n")
+	}
+	b.WriteString("--" + sep + "--\r\n")
+	return []byte(b.String())
+}
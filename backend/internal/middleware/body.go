@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyLimitKey はリクエストボディの上限を保存するコンテキストのキーです
+const bodyLimitKey = "middleware.body_limit"
+
+// BodyLimit はリクエストボディの大きさを limit までに制限するミドルウェアです
+// グループ全体に付けた制限の後にルートでも付けると、ルートの制限で上書きします（ファイルのアップロード用）
+// 上限はボディを読み始めた時点のものを使うため、Content-Length で超えていると分かる場合も読み込みで失敗します
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(bodyLimitKey, limit)
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			if _, ok := c.Request.Body.(*limitedBody); !ok {
+				c.Request.Body = &limitedBody{c: c, body: c.Request.Body}
+			}
+		}
+		c.Next()
+	}
+}
+
+// limitedBody は最初に読まれたときの上限でボディを制限します
+type limitedBody struct {
+	c      *gin.Context
+	body   io.ReadCloser
+	reader io.ReadCloser
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		limit := b.c.GetInt64(bodyLimitKey)
+		if limit <= 0 {
+			b.reader = b.body
+		} else if b.c.Request.ContentLength > limit {
+			return 0, &http.MaxBytesError{Limit: limit}
+		} else {
+			b.reader = http.MaxBytesReader(b.c.Writer, b.body, limit)
+		}
+	}
+	return b.reader.Read(p)
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate はトークンバケットの設定です
+// Per ごとに Count 回まで、最大 Count 回まで連続してリクエストできます
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// ParseRate は "60/1m" のような設定を解釈します
+// 空文字列・"0"・"off" は制限なし（ゼロ値）です
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Rate{}, nil
+	}
+	count, per, found := strings.Cut(s, "/")
+	if !found {
+		return Rate{}, fmt.Errorf("invalid rate %q (expected count/duration such as 60/1m)", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Rate{}, fmt.Errorf("invalid rate count %q", count)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate duration %q", per)
+	}
+	return Rate{Count: n, Per: d}, nil
+}
+
+// bucket はキーごとのトークンの残量です
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter はキー（IP アドレスやイベント ID）ごとのトークンバケットです
+type Limiter struct {
+	rate      Rate
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter は rate の Limiter を返します。rate がゼロ値の場合は nil（制限なし）です
+func NewLimiter(rate Rate) *Limiter {
+	if rate.Count == 0 {
+		return nil
+	}
+	return &Limiter{rate: rate, buckets: make(map[string]*bucket)}
+}
+
+// Allow は key のリクエストを許可するかを返します
+// 許可しない場合は次のトークンが貯まるまでの時間も返します
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.rate.Count)
+	perToken := l.rate.Per / time.Duration(l.rate.Count)
+
+	// 満タンに戻ったバケットはときどき捨てて、メモリを使い続けないようにする
+	if now.Sub(l.lastSweep) > l.rate.Per {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) >= l.rate.Per {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last)
+	b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/float64(perToken))
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// RateLimit は key ごとにリクエストを制限する gin のミドルウェアです
+// key が空文字列を返したリクエストと、l が nil の場合は制限しません
+func RateLimit(l *Limiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+		if ok, retryAfter := l.Allow(k, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ByIP はクライアントの IP アドレスで制限します
+// X-Forwarded-For などのヘッダーは、ルーターに設定した信頼するプロキシを経由した場合だけ使われます
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByEvent は :id のイベントごとに制限します
+func ByEvent(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return "event:" + id
+	}
+	return ""
+}
@@ -1,19 +1,47 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
 )
 
 // Event represents a schedule coordination event
 type Event struct {
-	ID           string        `json:"id" gorm:"primaryKey"`
-	Title        string        `json:"title" gorm:"not null"`
-	Description  string        `json:"description"`
-	Dates        []Date        `json:"dates" gorm:"foreignKey:EventID"`
-	Performances []Performance `json:"performances" gorm:"foreignKey:EventID"`
-	Responses    []Response    `json:"responses,omitempty" gorm:"foreignKey:EventID"`
-	CreatedAt    time.Time     `json:"created_at"`
-	UpdatedAt    time.Time     `json:"updated_at"`
+	ID             string        `json:"id" gorm:"primaryKey"`
+	Title          string        `json:"title" gorm:"not null"`
+	Description    string        `json:"description"`
+	Dates          []Date        `json:"dates" gorm:"foreignKey:EventID"`
+	Performances   []Performance `json:"performances" gorm:"foreignKey:EventID"`
+	Responses      []Response    `json:"responses,omitempty" gorm:"foreignKey:EventID"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	PasswordHash   string        `json:"-"`                                      // 閲覧パスワードの bcrypt ハッシュ（空なら誰でも閲覧できる）
+	Protected      bool          `json:"protected" gorm:"-"`                     // 閲覧にパスワードが必要か
+	OrganizationID *uint         `json:"organization_id,omitempty" gorm:"index"` // イベントを所有する団体（nil なら匿名のイベント）
+	UseRoster      bool          `json:"use_roster"`                             // 団体の名簿のメンバーとして回答を受け付けるか
+	// 回答の締め切り
+	Deadline             *time.Time `json:"deadline,omitempty"`             // これ以降は回答を受け付けない（nil なら締め切りなし）
+	AutoSchedule         bool       `json:"auto_schedule"`                  // 締め切り後に自動で最適化を実行する
+	AutoScheduleSessions int        `json:"auto_schedule_sessions"`         // 自動最適化の練習回数（0 ならセッション展開なし）
+	ReminderHours        int        `json:"reminder_hours"`                 // 締め切りの何時間前に未回答のメンバーへ催促するか（0 なら催促しない）
+	RemindedAt           *time.Time `json:"reminded_at,omitempty"`          // 催促を送った日時
+	ClosedAt             *time.Time `json:"closed_at,omitempty"`            // 締め切り後の処理を行った日時
+	AutoOptimizationID   string     `json:"auto_optimization_id,omitempty"` // 自動最適化のジョブID
+}
+
+// Closed は締め切りを過ぎて回答を受け付けないかを返します
+func (e Event) Closed(now time.Time) bool {
+	return e.Deadline != nil && !now.Before(*e.Deadline)
+}
+
+// AfterFind は読み込んだイベントに Protected を設定します
+func (e *Event) AfterFind(tx *gorm.DB) error {
+	e.Protected = e.PasswordHash != ""
+	return nil
 }
 
 // Date represents a date option for an event
@@ -33,20 +61,70 @@ type Performance struct {
 
 // Response represents a participant's response to the event
 type Response struct {
-	ID           uint              `json:"id" gorm:"primaryKey"`
-	EventID      string            `json:"event_id" gorm:"not null"`
-	Name         string            `json:"name" gorm:"not null"`
-	Answers      []ResponseAnswer  `json:"answers,omitempty" gorm:"foreignKey:ResponseID"`
-	Performances []UserPerformance `json:"performances,omitempty" gorm:"foreignKey:ResponseID"`
-	CreatedAt    time.Time         `json:"created_at"`
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	EventID        string            `json:"event_id" gorm:"not null;uniqueIndex:idx_responses_event_name"`
+	Name           string            `json:"name" gorm:"not null"`
+	NormalizedName *string           `json:"-" gorm:"uniqueIndex:idx_responses_event_name"` // NormalizeName した名前（同じイベントで一意。nil は一意にする前からある重複）
+	Answers        []ResponseAnswer  `json:"answers,omitempty" gorm:"foreignKey:ResponseID"`
+	Performances   []UserPerformance `json:"performances,omitempty" gorm:"foreignKey:ResponseID"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UserID         *uint             `json:"user_id,omitempty" gorm:"index"`   // ログインして回答したアカウント
+	MemberID       *uint             `json:"member_id,omitempty" gorm:"index"` // 名簿のメンバー
+}
+
+// SetName は回答の名前と正規化した名前を設定します
+func (r *Response) SetName(name string) {
+	normalized := NormalizeName(name)
+	r.Name = name
+	r.NormalizedName = &normalized
+}
+
+// BeforeCreate は正規化した名前が未設定の回答に設定します
+func (r *Response) BeforeCreate(tx *gorm.DB) error {
+	if r.NormalizedName == nil {
+		r.SetName(r.Name)
+	}
+	return nil
+}
+
+// nameFolder は名前の大文字・小文字を区別しないための変換です
+var nameFolder = cases.Fold()
+
+// NormalizeName は名前を照合用に正規化します
+// NFKC で全角英数字・半角カナ・全角空白などの幅の違いをそろえ、大文字・小文字を区別せず、
+// 前後の空白を除いて連続する空白を1つにまとめます
+func NormalizeName(name string) string {
+	name = nameFolder.String(norm.NFKC.String(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// AnswerStatus is a member's availability for a single date
+type AnswerStatus string
+
+// Answer statuses
+const (
+	StatusAvailable   AnswerStatus = "available"
+	StatusMaybe       AnswerStatus = "maybe"
+	StatusUnavailable AnswerStatus = "unavailable"
+	// StatusUnanswered は後から追加された日付など、まだ回答していない状態です
+	StatusUnanswered AnswerStatus = "unanswered"
+)
+
+// Valid reports whether the status is one of the known statuses
+func (s AnswerStatus) Valid() bool {
+	switch s {
+	case StatusAvailable, StatusMaybe, StatusUnavailable, StatusUnanswered:
+		return true
+	}
+	return false
 }
 
 // ResponseAnswer represents availability for a single date
 type ResponseAnswer struct {
-	ID         uint   `json:"id" gorm:"primaryKey"`
-	ResponseID uint   `json:"response_id" gorm:"not null"`
-	DateID     uint   `json:"date_id" gorm:"not null"`
-	Status     string `json:"status" gorm:"not null"` // "available", "maybe", "unavailable"
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	ResponseID uint         `json:"response_id" gorm:"not null"`
+	DateID     uint         `json:"date_id" gorm:"not null"`
+	Status     AnswerStatus `json:"status" gorm:"not null"`
 }
 
 // UserPerformance represents which performances a user participates in
@@ -64,21 +142,109 @@ type ConflictReport struct {
 }
 
 // CreateEventRequest represents the request to create a new event
+// TemplateID を指定した場合、空のタイトル・説明・パフォーマンスはテンプレートから補います
 type CreateEventRequest struct {
-	Title        string   `json:"title" binding:"required"`
-	Description  string   `json:"description"`
-	Dates        []string `json:"dates" binding:"required,min=1"`
-	Performances []struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description"`
-	} `json:"performances" binding:"required,min=1"`
+	TemplateID     *uint                `json:"template_id"`
+	OrganizationID *uint                `json:"organization_id"` // 指定した場合、ログインしたメンバーの団体のイベントになる
+	UseRoster      bool                 `json:"use_roster"`      // 団体の名簿と結び付ける（organization_id が必要）
+	Title          string               `json:"title"`
+	Description    string               `json:"description"`
+	Password       string               `json:"password"` // 指定した場合、閲覧にパスワードが必要になる
+	Dates          []string             `json:"dates"`
+	DateRules      []DateRuleRequest    `json:"date_rules" binding:"dive"`
+	Performances   []PerformanceRequest `json:"performances" binding:"dive"`
+	// 回答の締め切り（省略時は締め切りなし）
+	DeadlineRequest
+}
+
+// DeadlineRequest represents the response deadline settings of an event
+type DeadlineRequest struct {
+	Deadline             *time.Time `json:"deadline"`
+	AutoSchedule         bool       `json:"auto_schedule"`
+	AutoScheduleSessions int        `json:"auto_schedule_sessions" binding:"min=0"`
+	ReminderHours        int        `json:"reminder_hours" binding:"min=0,max=720"`
+}
+
+// DateRuleRequest represents a recurrence rule expanded into dates on the server
+// 例: {"rrule": "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20250630", "from": "2025-04-01",
+// "start": "19:00", "end": "21:00", "exclude": ["2025-04-29"]}
+type DateRuleRequest struct {
+	RRule   string   `json:"rrule" binding:"required"`
+	From    string   `json:"from" binding:"required"`  // 最初の日 "2025-04-01"
+	Start   string   `json:"start" binding:"required"` // 開始時刻 "19:00"
+	End     string   `json:"end" binding:"required"`   // 終了時刻 "21:00"
+	Exclude []string `json:"exclude"`                  // 除外する日（祝日など）
+}
+
+// AddDatesRequest represents the request to add candidate dates to an event
+type AddDatesRequest struct {
+	Dates     []string          `json:"dates"`
+	DateRules []DateRuleRequest `json:"date_rules" binding:"dive"`
+}
+
+// PerformanceRequest represents a performance in a create request
+type PerformanceRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CloneEventRequest represents the options to clone an event
+type CloneEventRequest struct {
+	Title            string `json:"title"`             // 省略時は元のタイトル
+	ShiftWeeks       int    `json:"shift_weeks"`       // 日程をずらす週数
+	KeepPerformances *bool  `json:"keep_performances"` // 省略時は true
+	InviteMembers    bool   `json:"invite_members"`    // 元のイベントの回答者を回答なしで登録する
+}
+
+// CreateSessionRequest represents the viewer password exchanged for a session token
+type CreateSessionRequest struct {
+	Password string `json:"password"`
+}
+
+// EventTemplate represents a reusable set of event settings
+// テンプレートは作成したユーザーと、団体を指定した場合はその団体のメンバーだけが使えます
+type EventTemplate struct {
+	ID             uint                  `json:"id" gorm:"primaryKey"`
+	Name           string                `json:"name" gorm:"not null"`
+	Title          string                `json:"title"`
+	Description    string                `json:"description"`
+	Performances   []TemplatePerformance `json:"performances" gorm:"foreignKey:TemplateID"`
+	CreatedBy      *uint                 `json:"created_by,omitempty" gorm:"index"`      // 作成したユーザー
+	OrganizationID *uint                 `json:"organization_id,omitempty" gorm:"index"` // 共有する団体（nil なら作成したユーザーだけ）
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+// TemplatePerformance represents a performance saved in an event template
+type TemplatePerformance struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	TemplateID  uint   `json:"template_id" gorm:"not null;index"`
+	Title       string `json:"title" gorm:"not null"`
+	Description string `json:"description"`
+}
+
+// CreateTemplateRequest represents the request to save an event template
+// EventID を指定した場合はそのイベントのタイトル・説明・パフォーマンスを保存します
+type CreateTemplateRequest struct {
+	Name           string               `json:"name" binding:"required"`
+	EventID        string               `json:"event_id"`
+	Title          string               `json:"title"`
+	Description    string               `json:"description"`
+	Performances   []PerformanceRequest `json:"performances" binding:"dive"`
+	OrganizationID *uint                `json:"organization_id"` // 指定した場合、その団体のメンバーで共有する
 }
 
 // CreateResponseRequest represents the request to add a new response
+// MemberID を指定した場合、Name は省略でき、名簿の表示名を使います
 type CreateResponseRequest struct {
-	Name         string          `json:"name" binding:"required"`
-	Answers      map[uint]string `json:"answers" binding:"required"`      // DateID -> Status
-	Performances []uint          `json:"performances" binding:"required"` // Array of PerformanceID
+	Name         string                `json:"name"`
+	MemberID     *uint                 `json:"member_id"`
+	Answers      map[uint]AnswerStatus `json:"answers" binding:"required"`      // DateID -> Status
+	Performances []uint                `json:"performances" binding:"required"` // Array of PerformanceID
+}
+
+// MergeResponsesRequest represents the duplicate responses merged into another response
+type MergeResponsesRequest struct {
+	ResponseIDs []uint `json:"response_ids" binding:"required,min=1"`
 }
 
 // ConflictAnalysisRequest represents a request to analyze conflicts
@@ -95,16 +261,64 @@ type ScoredOption struct {
 	AvailableCount   int      `json:"available_count"`
 	MaybeCount       int      `json:"maybe_count"`
 	UnavailableCount int      `json:"unavailable_count"`
+	UnansweredCount  int      `json:"unanswered_count"`
 	TotalCount       int      `json:"total_count"`
 	ConflictCount    int      `json:"conflict_count"`
 	WeightedScore    float64  `json:"weighted_score"`
 	ConflictingUsers []string `json:"conflicting_users"`
 }
 
+// ScheduledSession represents a confirmed practice session of a performance
+type ScheduledSession struct {
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	EventID       string      `json:"event_id" gorm:"not null;index"`
+	PerformanceID uint        `json:"performance_id" gorm:"not null"`
+	DateID        uint        `json:"date_id" gorm:"not null"`
+	Session       int         `json:"session"` // 1-based session number of the performance
+	Performance   Performance `json:"performance" gorm:"foreignKey:PerformanceID"`
+	Date          Date        `json:"date" gorm:"foreignKey:DateID"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// ConfirmScheduleRequest represents the request to confirm an event's schedule
+type ConfirmScheduleRequest struct {
+	Sessions []struct {
+		PerformanceID uint `json:"performance_id" binding:"required"`
+		DateID        uint `json:"date_id" binding:"required"`
+		Session       int  `json:"session"`
+	} `json:"sessions" binding:"required"`
+}
+
+// OptimizationJob represents a background optimization run for an event
+type OptimizationJob struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	EventID      string     `json:"event_id" gorm:"not null;index"`
+	Status       string     `json:"status" gorm:"not null"` // "queued", "running", "succeeded", "failed", "cancelled"
+	SessionCount int        `json:"session_count"`
+	Iteration    int        `json:"iteration"`
+	Iterations   int        `json:"max_iterations"`
+	Temperature  float64    `json:"temperature"`
+	Energy       float64    `json:"energy"`
+	BestEnergy   float64    `json:"best_energy"`
+	Result       string     `json:"-" gorm:"type:text"` // JSON encoded result
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"` // 実行中は定期的に更新される（止まっていればサーバーが落ちている）
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// CreateOptimizationRequest represents the request to start an optimization job
+type CreateOptimizationRequest struct {
+	Sessions   int    `json:"sessions"`   // 0 or omitted: single session per performance
+	Moves      string `json:"moves"`      // e.g. "relocate:4,swap:3"
+	Unanswered string `json:"unanswered"` // "optimistic", "pessimistic" or "maybe" (default)
+}
+
 type UserData struct {
 	Name         string
-	Performances map[uint]bool   // パフォーマンスID -> 参加するか
-	Availability map[uint]string // 日付ID -> 可用性状態
+	Performances map[uint]bool         // パフォーマンスID -> 参加するか
+	Availability map[uint]AnswerStatus // 日付ID -> 可用性状態（回答がない日付は含まない）
 }
 
 // func (d Date) Value() (driver.Value, error) {
@@ -125,3 +339,170 @@ type UserData struct {
 // 		return fmt.Errorf("unsupported type: %T", v)
 // 	}
 // }
+
+// User represents an account identified by its email address
+type User struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Email     string    `json:"email" gorm:"not null;uniqueIndex"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// 通知の設定
+	Locale          string `json:"locale" gorm:"not null;default:ja"`             // 通知メールの言語（ja / en）
+	NotifyResponses bool   `json:"notify_responses" gorm:"not null;default:true"` // 団体のイベントに回答が届いたとき
+	NotifySchedule  bool   `json:"notify_schedule" gorm:"not null;default:true"`  // 回答したイベントのスケジュールが確定したとき
+	NotifyReminders bool   `json:"notify_reminders" gorm:"not null;default:true"` // 未回答のイベントの締め切りが近づいたとき
+}
+
+// Organization represents a group (circle) that owns events
+type Organization struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	Name      string       `json:"name" gorm:"not null"`
+	Members   []Membership `json:"members,omitempty" gorm:"foreignKey:OrganizationID"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Membership roles
+const (
+	RoleOwner  = "owner"  // メンバーの管理ができる
+	RoleMember = "member" // 団体のイベントの閲覧・作成ができる
+)
+
+// Membership represents a user's membership in an organization
+type Membership struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_membership"`
+	UserID         uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_membership"`
+	User           User      `json:"user"`
+	Role           string    `json:"role" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LoginToken represents a one-time magic link token
+// トークンそのものは保存せず、SHA-256 のハッシュだけを保存します
+type LoginToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	Email     string    `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LoginRequest represents a request to send a magic link
+type LoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyLoginRequest represents a magic link token exchanged for a session token
+type VerifyLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UpdateUserRequest represents the editable account settings
+type UpdateUserRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// UpdateNotificationsRequest represents the request to change notification preferences
+// 省略した項目は変更しません
+type UpdateNotificationsRequest struct {
+	Locale          *string `json:"locale" binding:"omitempty,oneof=ja en"`
+	NotifyResponses *bool   `json:"notify_responses"`
+	NotifySchedule  *bool   `json:"notify_schedule"`
+	NotifyReminders *bool   `json:"notify_reminders"`
+}
+
+// CreateOrganizationRequest represents the request to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddMemberRequest represents the request to add a user to an organization
+type AddMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Name  string `json:"name"`
+	Role  string `json:"role" binding:"omitempty,oneof=owner member"` // 省略時は member
+}
+
+// RosterMember represents a member on an organization's roster
+// 回答の名前を表示名・別名と照合し、イベントをまたいで同じメンバーとして扱います
+type RosterMember struct {
+	ID                  uint                `json:"id" gorm:"primaryKey"`
+	OrganizationID      uint                `json:"organization_id" gorm:"not null;index"`
+	DisplayName         string              `json:"display_name" gorm:"not null"`
+	UserID              *uint               `json:"user_id,omitempty" gorm:"index"` // ログインに使うアカウント（任意）
+	Aliases             []RosterAlias       `json:"aliases" gorm:"foreignKey:MemberID"`
+	DefaultPerformances []RosterPerformance `json:"default_performances" gorm:"foreignKey:MemberID"`
+	CreatedAt           time.Time           `json:"created_at"`
+}
+
+// RosterAlias represents another name a roster member may answer with
+type RosterAlias struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	MemberID uint   `json:"member_id" gorm:"not null;index"`
+	Alias    string `json:"alias" gorm:"not null"`
+}
+
+// RosterPerformance represents a performance a roster member usually joins
+// イベントごとにパフォーマンスが異なるため、タイトルで照合します
+type RosterPerformance struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	MemberID uint   `json:"member_id" gorm:"not null;index"`
+	Title    string `json:"title" gorm:"not null"`
+}
+
+// RosterMemberRequest represents the request to create or update a roster member
+type RosterMemberRequest struct {
+	DisplayName         string   `json:"display_name" binding:"required"`
+	Aliases             []string `json:"aliases"`
+	DefaultPerformances []string `json:"default_performances"` // パフォーマンスのタイトル
+	UserID              *uint    `json:"user_id"`
+}
+
+// LinkRosterRequest represents the request to link or unlink an event and its organization's roster
+type LinkRosterRequest struct {
+	UseRoster bool `json:"use_roster"`
+}
+
+// Webhook represents an outgoing webhook subscription of an event
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   string    `json:"event_id" gorm:"not null;index"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"secret,omitempty"` // 署名用の鍵（作成時のレスポンスにだけ含める）
+	Events    string    `json:"-"`                // 通知する種類（カンマ区切り）
+	Types     []string  `json:"events" gorm:"-"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AfterFind は読み込んだ Webhook に Types を設定します
+func (w *Webhook) AfterFind(tx *gorm.DB) error {
+	w.Types = nil
+	if w.Events != "" {
+		w.Types = strings.Split(w.Events, ",")
+	}
+	return nil
+}
+
+// WebhookDelivery represents a queued or completed webhook delivery
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"not null;index"`
+	EventType      string     `json:"event_type" gorm:"not null"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"not null;index"` // pending / succeeded / failed
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhookRequest represents the request to subscribe a URL to event activity
+// Events を省略した場合は全ての種類を通知します
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events"`
+}
@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/logging"
+	"github.com/raie03/schedule-app/backend/internal/mail"
+)
+
+// 通知の言語
+const (
+	LocaleJA = "ja"
+	LocaleEN = "en"
+)
+
+// DefaultLocale は言語が未設定のユーザーに使う言語です
+const DefaultLocale = LocaleJA
+
+// 通知の種類
+const (
+	ResponseCreated   = "response_created"   // 団体のイベントに新しい回答が届いた（メンバー宛て）
+	ScheduleConfirmed = "schedule_confirmed" // 回答したイベントのスケジュールが確定した（回答者宛て）
+	DeadlineReminder  = "deadline_reminder"  // 締め切りが近いイベントにまだ回答していない（メンバー宛て）
+)
+
+// sendTimeout は1通の送信にかける時間の上限です
+const sendTimeout = 30 * time.Second
+
+// Recipient は通知の宛先です
+type Recipient struct {
+	Email  string
+	Name   string
+	Locale string
+}
+
+// ResponseCreatedData は ResponseCreated の通知のデータです
+type ResponseCreatedData struct {
+	EventTitle    string
+	EventURL      string
+	ResponseName  string
+	ResponseCount int64
+}
+
+// ScheduleConfirmedData は ScheduleConfirmed の通知のデータです
+type ScheduleConfirmedData struct {
+	EventTitle   string
+	EventURL     string
+	ResponseName string
+	Sessions     []Session
+}
+
+// DeadlineReminderData は DeadlineReminder の通知のデータです
+type DeadlineReminderData struct {
+	EventTitle string
+	EventURL   string
+	Deadline   string // 例: "2025-04-10 23:59"
+}
+
+// Session は通知に載せる確定した予定です
+type Session struct {
+	When  string // 例: "2025-04-15 18:00-20:00"
+	Title string
+}
+
+// messageTemplate は件名と本文のテンプレートです
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// templates は言語・種類ごとのテンプレートです
+// テンプレートには宛先（.To）と種類ごとのデータ（.Data）を渡します
+var templates = map[string]map[string]messageTemplate{
+	LocaleJA: {
+		ResponseCreated: parse(
+			`【{{.Data.EventTitle}}】{{.Data.ResponseName}} さんが回答しました`,
+			`{{if .To.Name}}{{.To.Name}} さん{{else}}こんにちは{{end}}
+
+「{{.Data.EventTitle}}」に {{.Data.ResponseName}} さんから回答が届きました（現在 {{.Data.ResponseCount}} 件）。
+
+{{.Data.EventURL}}
+
+この通知はアカウントの設定で停止できます。
+`),
+		ScheduleConfirmed: parse(
+			`【{{.Data.EventTitle}}】スケジュールが確定しました`,
+			`{{if .To.Name}}{{.To.Name}} さん{{else}}こんにちは{{end}}
+
+「{{.Data.EventTitle}}」のスケジュールが確定しました。{{.Data.ResponseName}} さんが参加する予定は次のとおりです。
+{{range .Data.Sessions}}
+- {{.When}}  {{.Title}}{{end}}{{if not .Data.Sessions}}
+（参加する予定はありません）{{end}}
+
+カレンダーに取り込める .ics ファイルを添付しています。
+
+{{.Data.EventURL}}
+
+この通知はアカウントの設定で停止できます。
+`),
+		DeadlineReminder: parse(
+			`【{{.Data.EventTitle}}】回答の締め切りが近づいています`,
+			`{{if .To.Name}}{{.To.Name}} さん{{else}}こんにちは{{end}}
+
+「{{.Data.EventTitle}}」の回答の締め切りは {{.Data.Deadline}} です。まだ回答していない場合は、締め切りまでに回答してください。
+
+{{.Data.EventURL}}
+
+この通知はアカウントの設定で停止できます。
+`),
+	},
+	LocaleEN: {
+		ResponseCreated: parse(
+			`[{{.Data.EventTitle}}] New response from {{.Data.ResponseName}}`,
+			`Hi{{if .To.Name}} {{.To.Name}}{{end}},
+
+{{.Data.ResponseName}} has responded to "{{.Data.EventTitle}}" ({{.Data.ResponseCount}} responses so far).
+
+{{.Data.EventURL}}
+
+You can turn off these emails in your account settings.
+`),
+		ScheduleConfirmed: parse(
+			`[{{.Data.EventTitle}}] The schedule has been confirmed`,
+			`Hi{{if .To.Name}} {{.To.Name}}{{end}},
+
+The schedule for "{{.Data.EventTitle}}" has been confirmed. Sessions for {{.Data.ResponseName}}:
+{{range .Data.Sessions}}
+- {{.When}}  {{.Title}}{{end}}{{if not .Data.Sessions}}
+(no sessions){{end}}
+
+The attached .ics file can be imported into your calendar.
+
+{{.Data.EventURL}}
+
+You can turn off these emails in your account settings.
+`),
+		DeadlineReminder: parse(
+			`[{{.Data.EventTitle}}] The response deadline is approaching`,
+			`Hi{{if .To.Name}} {{.To.Name}}{{end}},
+
+Responses to "{{.Data.EventTitle}}" close at {{.Data.Deadline}}. If you haven't answered yet, please do so before the deadline.
+
+{{.Data.EventURL}}
+
+You can turn off these emails in your account settings.
+`),
+	},
+}
+
+// parse はテンプレートを解析します（組み込みのテンプレートなので失敗したら panic します）
+func parse(subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// ValidLocale は言語が通知に対応しているかを返します
+func ValidLocale(locale string) bool {
+	_, ok := templates[locale]
+	return ok
+}
+
+// Render は宛先の言語で通知のメッセージを組み立てます
+// 対応していない言語の場合は DefaultLocale を使います
+func Render(to Recipient, kind string, data interface{}) (mail.Message, error) {
+	byKind, ok := templates[to.Locale]
+	if !ok {
+		byKind = templates[DefaultLocale]
+	}
+	tmpl, ok := byKind[kind]
+	if !ok {
+		return mail.Message{}, fmt.Errorf("unknown notification %q", kind)
+	}
+
+	values := struct {
+		To   Recipient
+		Data interface{}
+	}{to, data}
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, values); err != nil {
+		return mail.Message{}, err
+	}
+	if err := tmpl.body.Execute(&body, values); err != nil {
+		return mail.Message{}, err
+	}
+
+	return mail.Message{
+		To:      to.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    body.String(),
+	}, nil
+}
+
+// Notifier は通知メールをバックグラウンドで送信します
+// 送信に時間がかかってもリクエストを待たせないよう、結果はログにだけ残します
+type Notifier struct {
+	sender mail.Sender
+}
+
+// New は sender で送信する Notifier を作成します
+func New(sender mail.Sender) *Notifier {
+	return &Notifier{sender: sender}
+}
+
+// Notify は宛先ごとに通知を組み立てて送信します
+// 送信はリクエストが終わった後も続くため、ctx からはロガーだけを引き継ぎます
+func (n *Notifier) Notify(ctx context.Context, to Recipient, kind string, data interface{}, attachments ...mail.Attachment) {
+	logger := logging.FromContext(ctx).With("kind", kind, "to", to.Email)
+	msg, err := Render(to, kind, data)
+	if err != nil {
+		logger.Error("Failed to render notification", "error", err)
+		return
+	}
+	msg.Attachments = attachments
+
+	go func() {
+		ctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logger), sendTimeout)
+		defer cancel()
+		if err := n.sender.Send(ctx, msg); err != nil {
+			logger.Error("Failed to send notification", "error", err)
+		}
+	}()
+}
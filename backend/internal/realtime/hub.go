@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"sync"
+)
+
+// メッセージの種類
+const (
+	ResponseCreated   = "response.created"
+	ResponseUpdated   = "response.updated"
+	ResponseDeleted   = "response.deleted"
+	ScheduleConfirmed = "schedule.confirmed"
+	RosterCompleted   = "roster.completed" // 名簿の全員が回答した
+	EventClosed       = "event.closed"     // 回答の締め切りを過ぎた
+)
+
+// Message はイベントの購読者に配信される変更通知です
+type Message struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// subscriber はイベント1つ分の購読です
+type subscriber struct {
+	ch chan Message
+}
+
+// Hub はイベントIDごとの購読者にメッセージを配信するプロセス内の pub/sub です
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]map[*subscriber]struct{}
+}
+
+// NewHub は空の Hub を作成します
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[*subscriber]struct{})}
+}
+
+// Subscribe はイベントの購読を開始し、受信用チャネルと購読解除関数を返します
+func (h *Hub) Subscribe(eventID string) (<-chan Message, func()) {
+	sub := &subscriber{ch: make(chan Message, 16)}
+
+	h.mu.Lock()
+	if h.subs[eventID] == nil {
+		h.subs[eventID] = make(map[*subscriber]struct{})
+	}
+	h.subs[eventID][sub] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[eventID], sub)
+			if len(h.subs[eventID]) == 0 {
+				delete(h.subs, eventID)
+			}
+			h.mu.Unlock()
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// Publish はイベントの全購読者にメッセージを配信します
+// 受信が追いつかない購読者へのメッセージは破棄されます
+func (h *Hub) Publish(eventID string, msg Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs[eventID] {
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences は1つのルールから生成できる日付の上限です
+const MaxOccurrences = 366
+
+// maxSpanYears は繰り返しを展開する期間の上限（年）です
+const maxSpanYears = 5
+
+// maxPeriods は1つのルールを展開するときに調べる日・週・月・年の数の上限です
+// 期限のないルールでも展開する期間の終わりで止まるため、通常はここまで届きません
+const maxPeriods = 50000
+
+// weekdays は RRULE の BYDAY・WKST の曜日コードです
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Rule は RRULE です
+// FREQ は DAILY・WEEKLY・MONTHLY・YEARLY、ほかに INTERVAL・COUNT・UNTIL・WKST と、
+// DAILY・WEEKLY の BYDAY（"1MO" のような序数なし）、MONTHLY の BYMONTHDAY（正の日）に対応します
+// それ以外の指定は正しく展開できないため、エラーにします
+type Rule struct {
+	Frequency string         // "DAILY"・"WEEKLY"・"MONTHLY"・"YEARLY"
+	Interval  int            // 何日（何週・何か月・何年）ごとか
+	Weekdays  []time.Weekday // BYDAY（空なら制限なし。WEEKLY では開始日の曜日）
+	MonthDays []int          // BYMONTHDAY（空なら開始日の日）
+	WeekStart time.Weekday   // WKST（週の始まり）
+	Until     time.Time      // この時刻まで（ゼロ値なら制限なし）
+	UntilDate bool           // UNTIL が日付だけの場合はその日の終わりまで
+	Count     int            // 生成する回数（0 なら制限なし）
+}
+
+// Parse は "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20250630" のような RRULE を解釈します
+// 先頭の "RRULE:" は省略できます。UTC（末尾の Z）でない UNTIL は loc の日時として扱います
+func Parse(rrule string, loc *time.Location) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+	rrule = strings.TrimPrefix(strings.TrimSpace(rrule), "RRULE:")
+
+	for _, part := range strings.Split(rrule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return rule, fmt.Errorf("invalid rule part %q", part)
+		}
+		value = strings.ToUpper(strings.TrimSpace(value))
+
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.Frequency = value
+			default:
+				return rule, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, date, err := parseUntil(value, loc)
+			if err != nil {
+				return rule, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			rule.Until, rule.UntilDate = until, date
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdays[strings.TrimSpace(code)]
+				if !ok {
+					return rule, fmt.Errorf("unsupported BYDAY %q", code)
+				}
+				rule.Weekdays = append(rule.Weekdays, day)
+			}
+		case "BYMONTHDAY":
+			for _, s := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(s))
+				if err != nil || n < 1 || n > 31 {
+					return rule, fmt.Errorf("unsupported BYMONTHDAY %q", s)
+				}
+				rule.MonthDays = append(rule.MonthDays, n)
+			}
+			sort.Ints(rule.MonthDays)
+		case "WKST":
+			day, ok := weekdays[value]
+			if !ok {
+				return rule, fmt.Errorf("invalid WKST %q", value)
+			}
+			rule.WeekStart = day
+		default:
+			return rule, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	switch {
+	case rule.Frequency == "":
+		return rule, fmt.Errorf("FREQ is required")
+	case len(rule.Weekdays) > 0 && rule.Frequency != "DAILY" && rule.Frequency != "WEEKLY":
+		return rule, fmt.Errorf("unsupported BYDAY with FREQ=%s", rule.Frequency)
+	case len(rule.MonthDays) > 0 && rule.Frequency != "MONTHLY":
+		return rule, fmt.Errorf("unsupported BYMONTHDAY with FREQ=%s", rule.Frequency)
+	}
+	return rule, nil
+}
+
+// parseUntil は UNTIL の "20250630"・"20250630T235959Z"・"20250630T235959" を解釈します
+// 日付だけの場合は true を返します
+func parseUntil(value string, loc *time.Location) (time.Time, bool, error) {
+	if len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// afterUntil は開始時刻 t の回が UNTIL より後かを返します
+func (r Rule) afterUntil(t time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+	if r.UntilDate {
+		y, m, d := t.Date()
+		uy, um, ud := r.Until.Date()
+		return y > uy || (y == uy && (m > um || (m == um && d > ud)))
+	}
+	return t.After(r.Until)
+}
+
+// each は start から繰り返す回の開始時刻を時刻順に yield に渡します
+// UNTIL を過ぎるか yield が false を返すと終わります。COUNT は呼び出し側で数えます
+// 時刻は start のタイムゾーンの壁時計で保つため、夏時間をまたいでも同じ時刻になります
+func (r Rule) each(start time.Time, yield func(time.Time) (bool, error)) error {
+	loc := start.Location()
+	hour, min, sec := start.Clock()
+	y, m, d := start.Date()
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	// DAILY・WEEKLY は start からの日数で数える
+	onDay := func(offset int) time.Time {
+		return time.Date(y, m, d+offset, hour, min, sec, 0, loc)
+	}
+	// MONTHLY・YEARLY は2月30日のような存在しない日付を飛ばす
+	at := func(year int, month time.Month, day int) (time.Time, bool) {
+		t := time.Date(year, month, day, hour, min, sec, 0, loc)
+		return t, t.Day() == day && t.Month() == month
+	}
+
+	allowed := make(map[time.Weekday]bool, len(r.Weekdays))
+	for _, day := range r.Weekdays {
+		allowed[day] = true
+	}
+	if r.Frequency == "WEEKLY" && len(allowed) == 0 {
+		allowed[start.Weekday()] = true
+	}
+	monthDays := r.MonthDays
+	if len(monthDays) == 0 {
+		monthDays = []int{d}
+	}
+
+	// WEEKLY の INTERVAL は開始日を含む週（WKST 始まり）から数える
+	weekOffset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+
+	for k := 0; ; k += interval {
+		if k/interval >= maxPeriods {
+			return fmt.Errorf("the rule repeats too many times")
+		}
+
+		// k 番目の日・週・月・年の候補（時刻順）
+		var candidates []time.Time
+		switch r.Frequency {
+		case "DAILY":
+			if t := onDay(k); len(allowed) == 0 || allowed[t.Weekday()] {
+				candidates = append(candidates, t)
+			}
+		case "WEEKLY":
+			for i := 0; i < 7; i++ {
+				if t := onDay(7*k - weekOffset + i); allowed[t.Weekday()] {
+					candidates = append(candidates, t)
+				}
+			}
+		case "MONTHLY":
+			first := time.Date(y, m+time.Month(k), 1, 0, 0, 0, 0, loc)
+			for _, day := range monthDays {
+				if t, ok := at(first.Year(), first.Month(), day); ok {
+					candidates = append(candidates, t)
+				}
+			}
+		case "YEARLY":
+			if t, ok := at(y+k, m, d); ok {
+				candidates = append(candidates, t)
+			}
+		default:
+			return fmt.Errorf("unsupported FREQ %q", r.Frequency)
+		}
+
+		for _, t := range candidates {
+			if t.Before(start) {
+				continue
+			}
+			if r.afterUntil(t) {
+				return nil
+			}
+			more, err := yield(t)
+			if err != nil || !more {
+				return err
+			}
+		}
+	}
+}
+
+// Expand は start の日から繰り返す日付を返します（時刻は 0 時）
+// exclude に含まれる日付（"2006-01-02"）は生成せず、COUNT にも数えません
+// 候補日の一覧を作るため、UNTIL か COUNT のないルールはエラーです
+func (r Rule) Expand(start time.Time, exclude map[string]bool) ([]time.Time, error) {
+	if r.Until.IsZero() && r.Count == 0 {
+		return nil, fmt.Errorf("UNTIL or COUNT is required")
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	limit := start.AddDate(maxSpanYears, 0, 0)
+
+	var dates []time.Time
+	err := r.each(start, func(day time.Time) (bool, error) {
+		if r.Count > 0 && len(dates) >= r.Count {
+			return false, nil
+		}
+		if day.After(limit) {
+			return false, fmt.Errorf("the rule spans more than %d years", maxSpanYears)
+		}
+		if exclude[day.Format("2006-01-02")] {
+			return true, nil
+		}
+		if len(dates) >= MaxOccurrences {
+			return false, fmt.Errorf("the rule generates more than %d dates", MaxOccurrences)
+		}
+		dates = append(dates, day)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dates, nil
+}
+
+// Occurrences は start（DTSTART）から繰り返す回の開始時刻を、end より前のものだけ時刻順に返します
+// start が RRULE に当てはまらない場合は含みません（DTSTART を最初の回にするのは Set です）
+func (r Rule) Occurrences(start, end time.Time) ([]time.Time, error) {
+	var times []time.Time
+	err := r.each(start, func(t time.Time) (bool, error) {
+		if !t.Before(end) || (r.Count > 0 && len(times) >= r.Count) {
+			return false, nil
+		}
+		times = append(times, t)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return times, nil
+}
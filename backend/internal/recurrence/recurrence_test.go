@@ -0,0 +1,93 @@
+package recurrence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func formatTimes(times []time.Time, layout string) []string {
+	var got []string
+	for _, t := range times {
+		got = append(got, t.Format(layout))
+	}
+	return got
+}
+
+func TestOccurrencesKeepWallClockAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := Parse("FREQ=WEEKLY;INTERVAL=2;COUNT=3", newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 3, 1, 18, 30, 0, 0, newYork)
+	times, err := rule.Occurrences(start, start.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-03-01 18:30 EST", "2025-03-15 18:30 EDT", "2025-03-29 18:30 EDT"}
+	if got := formatTimes(times, "2006-01-02 15:04 MST"); !reflect.DeepEqual(got, want) {
+		t.Errorf("occurrences = %v, want %v", got, want)
+	}
+}
+
+func TestOccurrencesSkipMissingMonthDays(t *testing.T) {
+	rule, err := Parse("FREQ=MONTHLY;BYMONTHDAY=15,30;UNTIL=20250415", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 30, 9, 0, 0, 0, time.UTC)
+	times, err := rule.Occurrences(start, start.AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2月30日はなく、UNTIL が日付だけなので4月15日の回まで含む
+	want := []string{"01/30", "02/15", "03/15", "03/30", "04/15"}
+	if got := formatTimes(times, "01/02"); !reflect.DeepEqual(got, want) {
+		t.Errorf("occurrences = %v, want %v", got, want)
+	}
+}
+
+func TestExpandDoesNotCountExcludedDates(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := rule.Expand(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), map[string]bool{"2025-04-03": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-04-01", "2025-04-08", "2025-04-10"}
+	if got := formatTimes(days, "2006-01-02"); !reflect.DeepEqual(got, want) {
+		t.Errorf("dates = %v, want %v", got, want)
+	}
+}
+
+func TestSetStartsWithRDatesAndExDates(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=3", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	set := Set{
+		Start:   start,
+		Rule:    &rule,
+		RDates:  []time.Time{time.Date(2025, 5, 10, 10, 0, 0, 0, time.UTC)},
+		ExDates: []Instant{{Time: time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC), AllDay: true}},
+	}
+	starts, err := set.Starts(start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"05/01", "05/03", "05/10"}
+	if got := formatTimes(starts, "01/02"); !reflect.DeepEqual(got, want) {
+		t.Errorf("starts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package recurrence
+
+import (
+	"sort"
+	"time"
+)
+
+// Instant は EXDATE・RECURRENCE-ID のように特定の回を指す日時です
+// 日付だけの指定はその日のどの時刻の回にも当てはまります
+type Instant struct {
+	Time   time.Time
+	AllDay bool
+}
+
+// Matches は開始時刻 t の回に当てはまるかを返します
+func (in Instant) Matches(t time.Time) bool {
+	if in.AllDay {
+		y, m, d := t.Date()
+		iy, im, id := in.Time.Date()
+		return y == iy && m == im && d == id
+	}
+	return in.Time.Equal(t)
+}
+
+// Set は DTSTART・RRULE・RDATE・EXDATE を合わせた予定の繰り返しです
+type Set struct {
+	Start   time.Time // DTSTART（RRULE に当てはまらなくても最初の回になる）
+	Rule    *Rule     // RRULE（nil なら繰り返さない）
+	RDates  []time.Time
+	ExDates []Instant
+}
+
+// Excluded は開始時刻 t の回が EXDATE で除かれているかを返します
+func (s Set) Excluded(t time.Time) bool {
+	for _, in := range s.ExDates {
+		if in.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Starts は end より前に始まる回の開始時刻を時刻順に返します
+func (s Set) Starts(end time.Time) ([]time.Time, error) {
+	starts := []time.Time{s.Start}
+	if s.Rule != nil {
+		expanded, err := s.Rule.Occurrences(s.Start, end)
+		if err != nil {
+			return nil, err
+		}
+		if len(expanded) > 0 && expanded[0].Equal(s.Start) {
+			expanded = expanded[1:]
+		}
+		starts = append(starts, expanded...)
+	}
+	starts = append(starts, s.RDates...)
+
+	result := make([]time.Time, 0, len(starts))
+	for _, t := range starts {
+		if t.Before(end) && !s.Excluded(t) {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package timeslot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Slot は候補日時1つ分の開始・終了時刻です
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Parse は "2025-04-15 15:00-17:00" 形式の Date.Value を loc のタイムゾーンで解釈します
+// 終了時刻が開始時刻以前（"22:00-01:00" や "24:00"）の場合は翌日として扱います
+func Parse(value string, loc *time.Location) (Slot, error) {
+	datePart, timePart, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found {
+		return Slot{}, fmt.Errorf("invalid date value %q", value)
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(timePart), "-")
+	if !found {
+		return Slot{}, fmt.Errorf("invalid time range in %q", value)
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", datePart, loc)
+	if err != nil {
+		return Slot{}, fmt.Errorf("invalid date in %q", value)
+	}
+
+	startOffset, err := parseClock(startStr)
+	if err != nil {
+		return Slot{}, fmt.Errorf("invalid start time in %q", value)
+	}
+	endOffset, err := parseClock(endStr)
+	if err != nil {
+		return Slot{}, fmt.Errorf("invalid end time in %q", value)
+	}
+
+	start := at(day, startOffset)
+	end := at(day, endOffset)
+	if !end.After(start) {
+		end = at(day.AddDate(0, 0, 1), endOffset%(24*time.Hour))
+	}
+	return Slot{Start: start, End: end}, nil
+}
+
+// Format は Slot を "2025-04-15 15:00-17:00" 形式の Date.Value に変換します
+func Format(slot Slot, loc *time.Location) string {
+	start := slot.Start.In(loc)
+	end := slot.End.In(loc)
+	return fmt.Sprintf("%s %s-%s", start.Format("2006-01-02"), start.Format("15:04"), end.Format("15:04"))
+}
+
+// Overlaps は2つの時間帯が重なっているかを返します
+func (s Slot) Overlaps(other Slot) bool {
+	return s.Start.Before(other.End) && other.Start.Before(s.End)
+}
+
+// parseClock は "15:00" を 0 時からの経過時間に変換します（"24:00" も可）
+func parseClock(s string) (time.Duration, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &hour, &minute); err != nil {
+		return 0, err
+	}
+	if hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("clock out of range: %q", s)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// at は日付の 0 時に offset を加えた時刻を返します（夏時間をまたいでも壁時計の時刻を保つ）
+func at(day time.Time, offset time.Duration) time.Time {
+	hours := int(offset / time.Hour)
+	minutes := int((offset % time.Hour) / time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, 0, 0, day.Location())
+}
+
+// LoadLocation はタイムゾーン名を読み込みます
+// 読み込めない場合は日本標準時を返します
+func LoadLocation(name string) *time.Location {
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone("JST", 9*60*60)
+}
+
+// Intersect は slot のうち periods のいずれかと重なる部分を返します
+func Intersect(slot Slot, periods []Slot) []Slot {
+	var result []Slot
+	for _, p := range Merge(periods) {
+		start, end := slot.Start, slot.End
+		if p.Start.After(start) {
+			start = p.Start
+		}
+		if p.End.Before(end) {
+			end = p.End
+		}
+		if start.Before(end) {
+			result = append(result, Slot{Start: start, End: end})
+		}
+	}
+	return result
+}
+
+// Subtract は slots から busy の時間帯を取り除いた残りを返します
+func Subtract(slots []Slot, busy []Slot) []Slot {
+	busy = Merge(busy)
+
+	var result []Slot
+	for _, slot := range slots {
+		cursor := slot.Start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(slot.End) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				result = append(result, Slot{Start: cursor, End: b.Start})
+			}
+			cursor = b.End
+		}
+		if cursor.Before(slot.End) {
+			result = append(result, Slot{Start: cursor, End: slot.End})
+		}
+	}
+	return result
+}
+
+// Merge は時間帯を開始時刻順に並べ、重なりや隣接する時間帯をまとめます
+func Merge(slots []Slot) []Slot {
+	sorted := make([]Slot, 0, len(slots))
+	for _, s := range slots {
+		if s.Start.Before(s.End) {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []Slot
+	for _, s := range sorted {
+		if n := len(merged); n > 0 && !s.Start.After(merged[n-1].End) {
+			if s.End.After(merged[n-1].End) {
+				merged[n-1].End = s.End
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// Split は slot を length ごとの時間帯に分割します（length に満たない残りは捨てます）
+func Split(slot Slot, length time.Duration) []Slot {
+	if length <= 0 {
+		return []Slot{slot}
+	}
+
+	var result []Slot
+	for start := slot.Start; !start.Add(length).After(slot.End); start = start.Add(length) {
+		result = append(result, Slot{Start: start, End: start.Add(length)})
+	}
+	return result
+}
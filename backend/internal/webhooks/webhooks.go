@@ -0,0 +1,455 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+	"github.com/raie03/schedule-app/backend/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// 配信の状態
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Ping は接続確認用のテスト配信の種類です
+const Ping = "ping"
+
+// Types は購読できる通知の種類です
+var Types = []string{
+	realtime.ResponseCreated,
+	realtime.ResponseUpdated,
+	realtime.ResponseDeleted,
+	realtime.ScheduleConfirmed,
+	realtime.RosterCompleted,
+	realtime.EventClosed,
+}
+
+// ValidType は通知の種類が購読できるものかを返します
+func ValidType(t string) bool {
+	return slices.Contains(Types, t)
+}
+
+// Options は配信の設定です。ゼロ値の項目は既定値を使います
+type Options struct {
+	AllowPrivate bool          // ループバック・プライベートアドレスへの配信を許可する（開発・テスト用）
+	PollInterval time.Duration // 再試行を確認する間隔（既定 5 秒）
+	MaxAttempts  int           // 失敗とするまでの試行回数（既定 8 回）
+	BaseBackoff  time.Duration // 1 回目の再試行までの時間。以降は倍にしていく（既定 30 秒）
+	MaxBackoff   time.Duration // 再試行の間隔の上限（既定 1 時間）
+	Timeout      time.Duration // 1 回の配信のタイムアウト（既定 10 秒）
+	Workers      int           // 同時に配信する数（既定 4）。同じ Webhook への配信は1件ずつ順に送る
+}
+
+// Dispatcher はデータベースをキューとして Webhook を配信します
+// 配信に失敗したものは指数バックオフで再試行し、再起動しても未配信のものを引き継ぎます
+// 応答の遅い配信先があっても他の Webhook の配信が止まらないよう、Webhook ごとに並行して送ります
+type Dispatcher struct {
+	db     *gorm.DB
+	opts   Options
+	client *http.Client
+	wake   chan struct{}
+	slots  chan struct{} // 配信中のワーカーの数を Workers までに制限する
+
+	mu   sync.Mutex
+	busy map[uint]bool // 配信中の Webhook
+}
+
+// errPrivateAddress は配信先がプライベートアドレスであることを表します
+var errPrivateAddress = errors.New("webhook URL resolves to a private address")
+
+// blockedNets は IsPrivate などで判定できない、外部から届かないはずのアドレス範囲です
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // このネットワーク（0.0.0.0 以外も Linux ではローカルに届く）
+		"100.64.0.0/10",  // キャリアグレード NAT（クラウドの内部アドレスにも使われる）
+		"192.0.0.0/24",   // IETF プロトコル割り当て
+		"198.18.0.0/15",  // ベンチマーク用
+		"240.0.0.0/4",    // 予約済み（255.255.255.255 を含む）
+		"64:ff9b::/96",   // NAT64（IPv4 の内部アドレスに変換される）
+		"64:ff9b:1::/48", // ローカルの NAT64
+		"2002::/16",      // 6to4（IPv4 アドレスを埋め込む）
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// blockedIP は配信先として接続してはいけないアドレスかを返します
+func blockedIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	// ::ffff:10.0.0.1 のような IPv4 射影アドレスも IPv4 として調べる
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDispatcher は配信を行うゴルーチンを起動した Dispatcher を作成します
+func NewDispatcher(db *gorm.DB, opts Options) *Dispatcher {
+	d := newDispatcher(db, opts)
+	go d.run()
+	return d
+}
+
+// newDispatcher は配信のゴルーチンを起動せずに Dispatcher を作成します
+func newDispatcher(db *gorm.DB, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+
+	// 名前解決後のアドレスで確認するため、DNS で内部アドレスに向けられても接続しない
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if opts.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if blockedIP(net.ParseIP(host)) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &Dispatcher{
+		db:     db,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout, Transport: transport},
+		wake:   make(chan struct{}, 1),
+		slots:  make(chan struct{}, opts.Workers),
+		busy:   make(map[uint]bool),
+	}
+}
+
+// NewSecret は署名用の鍵を生成します
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign は配信の署名を計算します
+// 受信側は X-Webhook-Timestamp と本文から同じ値を計算し、X-Webhook-Signature の "sha256=" 以降と比較します
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// payload は配信する JSON です
+type payload struct {
+	Type      string      `json:"type"`
+	EventID   string      `json:"event_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Enqueue はイベントの Webhook のうち、種類を購読しているものへの配信をキューに登録します
+func (d *Dispatcher) Enqueue(eventID, eventType string, data interface{}) error {
+	var hooks []models.Webhook
+	if err := d.db.Where("event_id = ? AND active = ?", eventID, true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, hook := range hooks {
+		if len(hook.Types) > 0 && !slices.Contains(hook.Types, eventType) {
+			continue
+		}
+		delivery, err := newDelivery(hook, eventType, data)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := d.db.Create(&deliveries).Error; err != nil {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+// EnqueueTo は1つの Webhook への配信をキューに登録します（テスト配信用）
+func (d *Dispatcher) EnqueueTo(hook models.Webhook, eventType string, data interface{}) (models.WebhookDelivery, error) {
+	delivery, err := newDelivery(hook, eventType, data)
+	if err != nil {
+		return delivery, err
+	}
+	if err := d.db.Create(&delivery).Error; err != nil {
+		return delivery, err
+	}
+	d.notify()
+	return delivery, nil
+}
+
+// newDelivery は未配信の配信記録を作成します
+func newDelivery(hook models.Webhook, eventType string, data interface{}) (models.WebhookDelivery, error) {
+	now := time.Now()
+	body, err := json.Marshal(payload{Type: eventType, EventID: hook.EventID, CreatedAt: now, Data: data})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		EventType:     eventType,
+		Payload:       string(body),
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// notify は配信ゴルーチンを起こします
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run は配信時刻になった配信をワーカーに渡します
+func (d *Dispatcher) run() {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.deliverDue()
+	}
+}
+
+// dueBatch は1回に読み込む配信の数です
+const dueBatch = 100
+
+// deliverDue は配信時刻になった未配信の配信をワーカーに渡します
+// 配信中の Webhook への配信は飛ばし、その配信が終わったときに改めて確認します
+func (d *Dispatcher) deliverDue() {
+	query := d.db.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now())
+	if busy := d.busyWebhooks(); len(busy) > 0 {
+		query = query.Where("webhook_id NOT IN ?", busy)
+	}
+	var due []models.WebhookDelivery
+	err := query.Order("next_attempt_at").Limit(dueBatch).Find(&due).Error
+	if err != nil {
+		slog.Error("Failed to load webhook deliveries", "error", err)
+		return
+	}
+
+	for i := range due {
+		delivery := &due[i]
+		if !d.reserve(delivery.WebhookID) {
+			continue
+		}
+		d.slots <- struct{}{}
+		if !d.claim(delivery) {
+			<-d.slots
+			d.release(delivery.WebhookID)
+			continue
+		}
+		go func() {
+			defer func() {
+				<-d.slots
+				d.release(delivery.WebhookID)
+				// 同じ Webhook の次の配信を送る
+				d.notify()
+			}()
+			d.attempt(delivery)
+		}()
+	}
+	if len(due) == dueBatch {
+		d.notify()
+	}
+}
+
+// reserve は Webhook を配信中にします。既に配信中の場合は false を返します
+func (d *Dispatcher) reserve(webhookID uint) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.busy[webhookID] {
+		return false
+	}
+	d.busy[webhookID] = true
+	return true
+}
+
+// busyWebhooks は配信中の Webhook の ID を返します
+func (d *Dispatcher) busyWebhooks() []uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := make([]uint, 0, len(d.busy))
+	for id := range d.busy {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// release は Webhook の配信が終わったことを記録します
+func (d *Dispatcher) release(webhookID uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.busy, webhookID)
+}
+
+// claim は配信を送る権利を取得します
+// 複数のインスタンスが同じキューを見ていても、次の試行時刻を先に進めたインスタンスだけが送ります
+func (d *Dispatcher) claim(delivery *models.WebhookDelivery) bool {
+	lease := time.Now().Add(2 * d.opts.Timeout)
+	result := d.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, StatusPending, delivery.NextAttemptAt).
+		Update("next_attempt_at", lease)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	delivery.NextAttemptAt = lease
+	return true
+}
+
+// attempt は配信を1回試し、結果を保存します
+func (d *Dispatcher) attempt(delivery *models.WebhookDelivery) {
+	var hook models.Webhook
+	err := d.db.First(&hook, delivery.WebhookID).Error
+	switch {
+	case err != nil:
+		delivery.Status = StatusFailed
+		delivery.LastError = "webhook was removed"
+	case !hook.Active:
+		delivery.Status = StatusFailed
+		delivery.LastError = "webhook is disabled"
+	default:
+		d.send(hook, delivery)
+	}
+
+	if err := d.db.Save(delivery).Error; err != nil {
+		slog.Error("Failed to save webhook delivery", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "error", err)
+	}
+}
+
+// send は配信を送信し、結果に応じて状態・次の試行時刻を更新します
+func (d *Dispatcher) send(hook models.Webhook, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := d.post(hook, delivery)
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.opts.MaxAttempts || errors.Is(err, errPrivateAddress) {
+		delivery.Status = StatusFailed
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+}
+
+// backoff は attempts 回失敗した後、次に試すまでの時間です
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.BaseBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+// post は署名付きで配信先に POST します。2xx 以外はエラーです
+func (d *Dispatcher) post(hook models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "schedule-app-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return 0, errPrivateAddress
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raie03/schedule-app/backend/internal/models"
+)
+
+// received は配信先が受け取ったリクエストです
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver は status を返し、受け取ったリクエストを記録する配信先を起動します
+func newReceiver(t *testing.T, status int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, received{header: r.Header.Clone(), body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), requests...)
+	}
+}
+
+func pendingDelivery() *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:            7,
+		WebhookID:     3,
+		EventType:     "response.created",
+		Payload:       `{"type":"response.created","event_id":"abc","data":{"id":1}}`,
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	srv, requests := newReceiver(t, http.StatusNoContent)
+	d := newDispatcher(nil, Options{AllowPrivate: true})
+	hook := models.Webhook{ID: 3, URL: srv.URL, Secret: "top-secret"}
+	delivery := pendingDelivery()
+
+	d.send(hook, delivery)
+
+	if delivery.Status != StatusSucceeded || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("delivery = %+v, want one successful attempt", delivery)
+	}
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(got))
+	}
+	req := got[0]
+	if string(req.body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", req.body, delivery.Payload)
+	}
+	if req.header.Get("X-Webhook-Event") != "response.created" || req.header.Get("X-Webhook-Delivery") != "7" {
+		t.Errorf("event headers = %q, %q", req.header.Get("X-Webhook-Event"), req.header.Get("X-Webhook-Delivery"))
+	}
+
+	// 受信側と同じ手順で署名を検証する
+	mac := hmac.New(sha256.New, []byte("top-secret"))
+	mac.Write([]byte(req.header.Get("X-Webhook-Timestamp") + "."))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := req.header.Get("X-Webhook-Signature"); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+}
+
+func TestSendRetriesWithBackoffAndGivesUp(t *testing.T) {
+	srv, requests := newReceiver(t, http.StatusInternalServerError)
+	d := newDispatcher(nil, Options{
+		AllowPrivate: true,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   90 * time.Second,
+	})
+	hook := models.Webhook{ID: 3, URL: srv.URL, Secret: "s"}
+	delivery := pendingDelivery()
+
+	// 1 回目は 1 分後、2 回目は倍の 2 分ではなく上限の 90 秒後に再試行する
+	for i, wait := range []time.Duration{time.Minute, 90 * time.Second} {
+		before := time.Now()
+		d.send(hook, delivery)
+		if delivery.Status != StatusPending || delivery.Attempts != i+1 {
+			t.Fatalf("after attempt %d: status %s, attempts %d", i+1, delivery.Status, delivery.Attempts)
+		}
+		if delivery.LastStatusCode != http.StatusInternalServerError || !strings.Contains(delivery.LastError, "unexpected status 500") {
+			t.Errorf("after attempt %d: last status %d, error %q", i+1, delivery.LastStatusCode, delivery.LastError)
+		}
+		if next := delivery.NextAttemptAt; next.Before(before.Add(wait)) || next.After(time.Now().Add(wait)) {
+			t.Errorf("after attempt %d: next attempt in %s, want %s", i+1, next.Sub(before), wait)
+		}
+	}
+
+	d.send(hook, delivery)
+	if delivery.Status != StatusFailed || delivery.Attempts != 3 {
+		t.Fatalf("after the last attempt: status %s, attempts %d, want failed after 3", delivery.Status, delivery.Attempts)
+	}
+	if n := len(requests()); n != 3 {
+		t.Errorf("receiver got %d requests, want 3", n)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	srv, requests := newReceiver(t, http.StatusOK)
+	d := newDispatcher(nil, Options{})
+	delivery := pendingDelivery()
+
+	// httptest のサーバーはループバックアドレスで待ち受ける
+	d.send(models.Webhook{ID: 3, URL: srv.URL, Secret: "s"}, delivery)
+
+	if delivery.Status != StatusFailed || delivery.LastError != errPrivateAddress.Error() {
+		t.Fatalf("delivery = status %s, error %q; want failed without retrying", delivery.Status, delivery.LastError)
+	}
+	if n := len(requests()); n != 0 {
+		t.Errorf("receiver got %d requests, want none", n)
+	}
+}
+
+func TestBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"198.18.0.1", true},
+		{"255.255.255.255", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"8.8.8.8", false},
+		{"100.63.255.255", false},
+		{"100.128.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := blockedIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("blockedIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}